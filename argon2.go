@@ -15,6 +15,7 @@
 package gophc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -75,14 +76,46 @@ var (
 	ErrInvalidArgon2Version = errors.New("invalid argon2 version")
 )
 
+// the maximum length of the optional keyid and data parameters as defined in the phc format
+const (
+	Argon2MaxKeyIDLength = 8
+	Argon2MaxDataLength  = 32
+)
+
+// limits from RFC 9106, section 3.1
+const (
+	Argon2MaxParallelism = 1<<24 - 1
+	Argon2MinSaltLength  = 8
+	Argon2MinHashLength  = 4
+	// the memory size must be at least 8 * p KiB
+	argon2MinMemoryPerLane = 8
+)
+
 type Argon2PHC struct {
 	Variant string
 	Version uint32
 	M       uint32
 	T       uint32
-	P       uint8
+	P       uint32
+	// KeyID is the optional id of the secret key, it is not used to compute the hash
+	KeyID []byte
+	// Data is the optional associated data
+	Data []byte
+	// PepperID is the optional id of the pepper used to compute the hash, see Pepper
+	PepperID []byte
+	// Normalization is the profile applied to the password before it is hashed, see NormalizePassword
+	Normalization string
+	Salt          []byte
+	SaltString    string
+	Hash          []byte
+	HashString    string
+	// Extra contains the parameters not described by the schema, see PreserveUnknownParameters and ParameterOptions.
+	// They're written by Encode, but not used to compute the hash and not copied to new hashes.
+	Extra []ParameterValuePair
 }
 
+// ValidateParameters checks all constraints of RFC 9106 (and the limits on keyid and data from the phc format).
+// Salt and hash are only checked if they're not empty, this way a parameters only instance can be validated.
 func (phc *Argon2PHC) ValidateParameters() error {
 	if !isValidArgon2Variant(phc.Variant) {
 		return NewMismatchedFunctionNameError(phc.Variant, Argon2Variants...)
@@ -97,10 +130,37 @@ func (phc *Argon2PHC) ValidateParameters() error {
 	if phc.T < 1 {
 		return wrapParameterValueErrorToPHCError("must be > 0", "t", nil)
 	}
-	if phc.P < 1 {
-		return wrapParameterValueErrorToPHCError("must be > 0", "p", nil)
+	if phc.P < 1 || phc.P > Argon2MaxParallelism {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("must be between 1 <= p <= %d, got %d", Argon2MaxParallelism, phc.P),
+			"p", nil)
+	}
+	if uint64(phc.M) < argon2MinMemoryPerLane*uint64(phc.P) {
+		return wrapMultipleParametersValueErrorToPHCError(fmt.Sprintf("m must be >= 8*p, got m=%d and p=%d", phc.M, phc.P), nil,
+			"m", "p")
+	}
+	if len(phc.Salt) > 0 && len(phc.Salt) < Argon2MinSaltLength {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("must have at least %d bytes, got %d", Argon2MinSaltLength, len(phc.Salt)),
+			"salt", nil)
 	}
-	return nil
+	if len(phc.Hash) > 0 && len(phc.Hash) < Argon2MinHashLength {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("must have at least %d bytes, got %d", Argon2MinHashLength, len(phc.Hash)),
+			"hash", nil)
+	}
+	if uint64(len(phc.Salt)) > maxUint32 || uint64(len(phc.Hash)) > maxUint32 {
+		return wrapMultipleParametersValueErrorToPHCError("length must fit into 32 bits", nil, "salt", "hash")
+	}
+	if len(phc.KeyID) > Argon2MaxKeyIDLength {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("must have at most %d bytes, got %d", Argon2MaxKeyIDLength, len(phc.KeyID)),
+			"keyid", nil)
+	}
+	if len(phc.Data) > Argon2MaxDataLength {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("must have at most %d bytes, got %d", Argon2MaxDataLength, len(phc.Data)),
+			"data", nil)
+	}
+	if err := validatePepperID(phc.PepperID); err != nil {
+		return err
+	}
+	return validateNormalization(phc.Normalization)
 }
 
 var Argon2Schema = &PHCSchema{
@@ -130,17 +190,42 @@ var Argon2Schema = &PHCSchema{
 			Optional:      false,
 			ValidateValue: NoValueValidator,
 		},
+		{
+			Name:          "keyid",
+			Default:       "",
+			Optional:      true,
+			ValidateValue: ValueCharacterValidator,
+		},
+		{
+			Name:          "data",
+			Default:       "",
+			Optional:      true,
+			ValidateValue: ValueCharacterValidator,
+		},
+		{
+			Name:          "pid",
+			Default:       "",
+			Optional:      true,
+			ValidateValue: ValueCharacterValidator,
+		},
+		{
+			Name:          "norm",
+			Default:       "",
+			Optional:      true,
+			ValidateValue: ValueCharacterValidator,
+		},
 	},
 	Decoder: DefaultBase64,
+	Encoder: DefaultBase64,
 }
 
-func argon2FromStringParams(variant string, versionParam, mParam, tParam, pParam ParameterValuePair, salt, hash []byte, saltString, hashString string) (*Argon2PHC, error) {
+func argon2FromStringParams(variant string, versionParam, mParam, tParam, pParam, keyIDParam, dataParam ParameterValuePair, salt, hash []byte, saltString, hashString string) (*Argon2PHC, error) {
 	if !isValidArgon2Variant(variant) {
 		return nil, NewMismatchedFunctionNameError(variant, Argon2Variants...)
 	}
 
 	var version, m, t uint32
-	var p uint8
+	var p uint32
 
 	if version64, versionErr := decodeNoneZeroUnsignedString(versionParam.Value, false, 32); versionErr == nil {
 		version = uint32(version64)
@@ -160,37 +245,255 @@ func argon2FromStringParams(variant string, versionParam, mParam, tParam, pParam
 		return nil, wrapParameterValueErrorToPHCError("can't parse as integer", tParam.Name, tErr)
 	}
 
-	if p64, pErr := decodeNoneZeroUnsignedString(pParam.Value, false, 8); pErr == nil {
-		p = uint8(p64)
+	if p64, pErr := decodeNoneZeroUnsignedString(pParam.Value, false, 24); pErr == nil {
+		p = uint32(p64)
 	} else {
 		return nil, wrapParameterValueErrorToPHCError("can't parse as integer", pParam.Name, pErr)
 	}
 
+	var keyID, data []byte
+	if keyIDParam.IsSet {
+		var keyIDErr error
+		keyID, keyIDErr = Argon2Schema.decodeBase64(keyIDParam.Value)
+		if keyIDErr != nil {
+			return nil, wrapParameterValueErrorToPHCError("can't decode base64", keyIDParam.Name, keyIDErr)
+		}
+	}
+	if dataParam.IsSet {
+		var dataErr error
+		data, dataErr = Argon2Schema.decodeBase64(dataParam.Value)
+		if dataErr != nil {
+			return nil, wrapParameterValueErrorToPHCError("can't decode base64", dataParam.Name, dataErr)
+		}
+	}
+
 	res := &Argon2PHC{
-		Variant: variant,
-		Version: version,
-		M:       m,
-		T:       t,
-		P:       p,
+		Variant:    variant,
+		Version:    version,
+		M:          m,
+		T:          t,
+		P:          p,
+		KeyID:      keyID,
+		Data:       data,
+		Salt:       salt,
+		SaltString: saltString,
+		Hash:       hash,
+		HashString: hashString,
 	}
 	return res, nil
 }
 
+// DecodeArgon2 decodes an argon2 phc string, the DefaultLimits are enforced.
 func DecodeArgon2(phcString string) (*Argon2PHC, error) {
-	instance, err := Argon2Schema.Decode(phcString)
+	return decodeArgon2(phcString, DefaultLimits, ParameterOptions{})
+}
+
+// DecodeArgon2WithOptions is like DecodeArgon2, but matches the parameters according to options.
+func DecodeArgon2WithOptions(phcString string, options ParameterOptions) (*Argon2PHC, error) {
+	return decodeArgon2(phcString, DefaultLimits, options)
+}
+
+func decodeArgon2(phcString string, limits *Limits, options ParameterOptions) (*Argon2PHC, error) {
+	if limitErr := limits.CheckString(phcString); limitErr != nil {
+		return nil, limitErr
+	}
+	instance, err := Argon2Schema.withOptions(options).Decode(phcString)
 	if err != nil {
 		return nil, err
 	}
 	// just an assertion, should never happen
-	if len(instance.Parameters) != 4 {
-		return nil, fmt.Errorf("internal error: expected exactly threeparameters, got %d instead", len(instance.Parameters))
+	if len(instance.Parameters) != 8 {
+		return nil, fmt.Errorf("internal error: expected exactly 8 parameters, got %d instead", len(instance.Parameters))
 	}
 	vParam := instance.Parameters[0]
 	mParam := instance.Parameters[1]
 	tParam := instance.Parameters[2]
 	pParam := instance.Parameters[3]
+	keyIDParam := instance.Parameters[4]
+	dataParam := instance.Parameters[5]
+	pidParam := instance.Parameters[6]
+	normParam := instance.Parameters[7]
 	variant := instance.Function
-	return argon2FromStringParams(
-		variant, vParam, mParam, tParam, pParam, instance.Salt, instance.Hash,
+	res, paramsErr := argon2FromStringParams(
+		variant, vParam, mParam, tParam, pParam, keyIDParam, dataParam, instance.Salt, instance.Hash,
 		instance.SaltString, instance.HashString)
+	if paramsErr != nil {
+		return nil, paramsErr
+	}
+	pepperID, pidErr := decodePepperID(Argon2Schema, pidParam)
+	if pidErr != nil {
+		return nil, pidErr
+	}
+	res.PepperID = pepperID
+	res.Normalization = normParam.Value
+	res.Extra = instance.Extra
+	if normErr := validateNormalization(res.Normalization); normErr != nil {
+		return nil, normErr
+	}
+	if limitErr := limits.CheckArgon2(res); limitErr != nil {
+		return nil, limitErr
+	}
+	return res, nil
+}
+
+// Encode returns the phc string of the instance.
+// The version is omitted if it is the default version, keyid, data, pid and norm are omitted if they're empty.
+func (phc *Argon2PHC) Encode() (string, error) {
+	version := ParameterValuePair{Name: "v"}
+	if phc.Version != defaultArgon2Version {
+		version.Value = strconv.FormatUint(uint64(phc.Version), 10)
+		version.IsSet = true
+	}
+	instance := &PHCInstance{
+		Function: phc.Variant,
+		Parameters: []ParameterValuePair{
+			version,
+			{Name: "m", Value: strconv.FormatUint(uint64(phc.M), 10), IsSet: true},
+			{Name: "t", Value: strconv.FormatUint(uint64(phc.T), 10), IsSet: true},
+			{Name: "p", Value: strconv.FormatUint(uint64(phc.P), 10), IsSet: true},
+			{Name: "keyid", Value: Argon2Schema.encodeBase64(phc.KeyID), IsSet: len(phc.KeyID) > 0},
+			{Name: "data", Value: Argon2Schema.encodeBase64(phc.Data), IsSet: len(phc.Data) > 0},
+			{Name: "pid", Value: Argon2Schema.encodeBase64(phc.PepperID), IsSet: len(phc.PepperID) > 0},
+			{Name: "norm", Value: phc.Normalization, IsSet: phc.Normalization != NormalizationNone},
+		},
+		Extra: phc.Extra,
+		Salt:  phc.Salt,
+		Hash:  phc.Hash,
+	}
+	return Argon2Schema.Encode(instance)
+}
+
+// HashPassword computes a new hash of the password with the parameters of phc and a new random salt.
+// phc is used as a template and is not changed, the salt has the length of phc.Salt and the hash the length of
+// phc.Hash. If they're empty the salt length of DefaultSaltGenerator and DefaultHashLength are used.
+func (phc *Argon2PHC) HashPassword(password []byte) (*Argon2PHC, error) {
+	return phc.HashPasswordWithSecret(password, nil)
+}
+
+// HashPasswordWithSecret is like HashPassword, but computes the hash with the secret key K.
+func (phc *Argon2PHC) HashPasswordWithSecret(password, secret []byte) (*Argon2PHC, error) {
+	return phc.hashContext(context.Background(), password, secret, DefaultLimits, true)
+}
+
+// HashContext is like HashPassword, but stops early if ctx is done, see Argon2KeyContext.
+func (phc *Argon2PHC) HashContext(ctx context.Context, password []byte) (*Argon2PHC, error) {
+	return phc.hashContext(ctx, password, nil, DefaultLimits, true)
+}
+
+func (phc *Argon2PHC) hashLimits(ctx context.Context, password []byte, limits *Limits) (PasswordHash, error) {
+	return phc.hashContext(ctx, password, nil, limits, true)
+}
+
+// hashContext computes a new hash, the password is normalized only if normalize is true.
+func (phc *Argon2PHC) hashContext(ctx context.Context, password, secret []byte, limits *Limits, normalize bool) (*Argon2PHC, error) {
+	res := phc.template()
+	if err := res.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	if limitErr := limits.CheckArgon2(res); limitErr != nil {
+		return nil, limitErr
+	}
+	saltLength, hashLength := len(phc.Salt), len(phc.Hash)
+	if saltLength == 0 {
+		saltLength = DefaultSaltGenerator.Length(res.Variant)
+	}
+	if hashLength == 0 {
+		hashLength = DefaultHashLength
+	}
+	// the template salt is not validated with the parameters and the generator might return a shorter length
+	if saltLength < Argon2MinSaltLength {
+		return nil, wrapParameterValueErrorToPHCError(fmt.Sprintf("must have at least %d bytes, got %d", Argon2MinSaltLength, saltLength),
+			"salt", nil)
+	}
+	salt, saltErr := DefaultSaltGenerator.GenerateN(saltLength)
+	if saltErr != nil {
+		return nil, saltErr
+	}
+	res.Salt = salt
+	hash, hashErr := res.computeHash(ctx, password, secret, uint32(hashLength), normalize)
+	if hashErr != nil {
+		return nil, hashErr
+	}
+	res.Hash = hash
+	res.SaltString = Argon2Schema.encodeBase64(res.Salt)
+	res.HashString = Argon2Schema.encodeBase64(res.Hash)
+	return res, nil
+}
+
+// NewHash implements PasswordHasher, see HashPassword.
+func (phc *Argon2PHC) NewHash(password []byte) (PasswordHash, error) {
+	return phc.HashPassword(password)
+}
+
+// NewHashContext implements ContextPasswordHasher, see HashContext.
+func (phc *Argon2PHC) NewHashContext(ctx context.Context, password []byte) (PasswordHash, error) {
+	return phc.HashContext(ctx, password)
+}
+
+// template returns a copy of the parameters of phc without salt and hash.
+func (phc *Argon2PHC) template() *Argon2PHC {
+	return &Argon2PHC{
+		Variant:       phc.Variant,
+		Version:       phc.Version,
+		M:             phc.M,
+		T:             phc.T,
+		P:             phc.P,
+		KeyID:         phc.KeyID,
+		Data:          phc.Data,
+		PepperID:      phc.PepperID,
+		Normalization: phc.Normalization,
+	}
+}
+
+// computeHash normalizes the password (if normalize is true) and computes the key.
+func (phc *Argon2PHC) computeHash(ctx context.Context, password, secret []byte, keyLen uint32, normalize bool) ([]byte, error) {
+	normalized, wipe, normErr := normalizeIf(normalize, phc.Normalization, password)
+	if normErr != nil {
+		return nil, normErr
+	}
+	if wipe {
+		defer wipeBytes(normalized)
+	}
+	return Argon2KeyContext(ctx, phc.Variant, phc.Version, normalized, phc.Salt, secret, phc.Data, phc.T, phc.M, phc.P, keyLen)
+}
+
+// Verify checks if the password matches the hash.
+//
+// An error is returned if the parameters are invalid or no hash is given.
+func (phc *Argon2PHC) Verify(password []byte) (bool, error) {
+	return phc.VerifyWithSecret(password, nil)
+}
+
+// VerifyWithSecret checks if the password matches the hash, the hash was computed with the secret key K.
+// The secret is usually identified by KeyID.
+//
+// The DefaultLimits are checked before the hash is computed.
+func (phc *Argon2PHC) VerifyWithSecret(password, secret []byte) (bool, error) {
+	return phc.verifyContext(context.Background(), password, secret, DefaultLimits, true)
+}
+
+// VerifyContext is like Verify, but stops early if ctx is done, see Argon2KeyContext.
+func (phc *Argon2PHC) VerifyContext(ctx context.Context, password []byte) (bool, error) {
+	return phc.verifyContext(ctx, password, nil, DefaultLimits, true)
+}
+
+func (phc *Argon2PHC) verifyLimits(ctx context.Context, password []byte, limits *Limits) (bool, error) {
+	return phc.verifyContext(ctx, password, nil, limits, true)
+}
+
+func (phc *Argon2PHC) verifyContext(ctx context.Context, password, secret []byte, limits *Limits, normalize bool) (bool, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return false, err
+	}
+	if limitErr := limits.CheckArgon2(phc); limitErr != nil {
+		return false, limitErr
+	}
+	if len(phc.Hash) == 0 {
+		return false, NewPHCError("can't verify password", ErrMissingHash)
+	}
+	computed, err := phc.computeHash(ctx, password, secret, uint32(len(phc.Hash)), normalize)
+	if err != nil {
+		return false, err
+	}
+	return equalAndWipe(computed, phc.Hash), nil
 }
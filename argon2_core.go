@@ -0,0 +1,370 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// A pure Go implementation of Argon2 as described in RFC 9106, the structure follows the implementation in
+// golang.org/x/crypto/argon2. In contrast to golang.org/x/crypto/argon2 all three variants, version 1.0 (0x10)
+// and version 1.3 (0x13) are supported and a secret key and associated data can be used.
+
+const (
+	argon2d = iota
+	argon2i
+	argon2id
+)
+
+const (
+	argon2BlockLength = 128
+	argon2SyncPoints  = 4
+)
+
+type argon2Block [argon2BlockLength]uint64
+
+func argon2Mode(variant string) (int, bool) {
+	switch variant {
+	case "argon2d":
+		return argon2d, true
+	case "argon2i":
+		return argon2i, true
+	case "argon2id":
+		return argon2id, true
+	default:
+		return -1, false
+	}
+}
+
+// Argon2Key computes the argon2 tag for the password.
+//
+// variant must be one of Argon2Variants and version one of Argon2Versions. secret and data are the optional
+// secret key K and the associated data X, they may be nil. memory is given in KiB.
+// The lanes are processed concurrently, one goroutine per lane.
+func Argon2Key(variant string, version uint32, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) ([]byte, error) {
+	return Argon2KeyContext(context.Background(), variant, version, password, salt, secret, data, time, memory, threads, keyLen)
+}
+
+// Argon2KeyContext is like Argon2Key, but stops early if ctx is done and returns ctx.Err() in this case.
+//
+// The context is checked before each of the four slices of every pass, so the computation stops after at most
+// 1/4 of a pass once ctx is done.
+func Argon2KeyContext(ctx context.Context, variant string, version uint32, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) ([]byte, error) {
+	mode, validMode := argon2Mode(variant)
+	if !validMode {
+		return nil, NewMismatchedFunctionNameError(variant, Argon2Variants...)
+	}
+	if !isValidArgon2Version(version) {
+		return nil, wrapParameterValueErrorToPHCError(fmt.Sprintf("invalid version %d", version), "v", ErrInvalidArgon2Version)
+	}
+	if time < 1 {
+		return nil, wrapParameterValueErrorToPHCError("must be > 0", "t", nil)
+	}
+	if threads < 1 || threads > Argon2MaxParallelism {
+		return nil, wrapParameterValueErrorToPHCError(fmt.Sprintf("must be between 1 <= p <= %d", Argon2MaxParallelism), "p", nil)
+	}
+	if keyLen < 1 {
+		return nil, wrapParameterValueErrorToPHCError("must be > 0", "hash", nil)
+	}
+	return argon2DeriveKey(ctx, mode, version, password, salt, secret, data, time, memory, threads, keyLen)
+}
+
+func argon2DeriveKey(ctx context.Context, mode int, version uint32, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) ([]byte, error) {
+	h0 := argon2InitHash(password, salt, secret, data, time, memory, threads, keyLen, mode, version)
+
+	memory = memory / (argon2SyncPoints * threads) * (argon2SyncPoints * threads)
+	if memory < 2*argon2SyncPoints*threads {
+		memory = 2 * argon2SyncPoints * threads
+	}
+	blocks := argon2InitBlocks(&h0, memory, threads)
+	if err := argon2ProcessBlocks(ctx, blocks, time, memory, threads, mode, version); err != nil {
+		return nil, err
+	}
+	return argon2ExtractKey(blocks, memory, threads, keyLen), nil
+}
+
+func argon2InitHash(password, salt, secret, data []byte, time, memory, threads, keyLen uint32, mode int, version uint32) [blake2b.Size + 8]byte {
+	var (
+		h0     [blake2b.Size + 8]byte
+		params [24]byte
+		tmp    [4]byte
+	)
+
+	b2, _ := blake2b.New512(nil)
+	binary.LittleEndian.PutUint32(params[0:4], threads)
+	binary.LittleEndian.PutUint32(params[4:8], keyLen)
+	binary.LittleEndian.PutUint32(params[8:12], memory)
+	binary.LittleEndian.PutUint32(params[12:16], time)
+	binary.LittleEndian.PutUint32(params[16:20], version)
+	binary.LittleEndian.PutUint32(params[20:24], uint32(mode))
+	b2.Write(params[:])
+	for _, input := range [][]byte{password, salt, secret, data} {
+		binary.LittleEndian.PutUint32(tmp[:], uint32(len(input)))
+		b2.Write(tmp[:])
+		b2.Write(input)
+	}
+	b2.Sum(h0[:0])
+	return h0
+}
+
+func argon2InitBlocks(h0 *[blake2b.Size + 8]byte, memory, threads uint32) []argon2Block {
+	var block0 [1024]byte
+	blocks := make([]argon2Block, memory)
+	for lane := uint32(0); lane < threads; lane++ {
+		j := lane * (memory / threads)
+		binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(h0[blake2b.Size:], i)
+			argon2Blake2bHash(block0[:], h0[:])
+			for k := range blocks[j+i] {
+				blocks[j+i][k] = binary.LittleEndian.Uint64(block0[k*8:])
+			}
+		}
+	}
+	return blocks
+}
+
+func argon2ProcessBlocks(ctx context.Context, blocks []argon2Block, time, memory, threads uint32, mode int, version uint32) error {
+	lanes := memory / threads
+	segments := lanes / argon2SyncPoints
+
+	processSegment := func(n, slice, lane uint32, wg *sync.WaitGroup) {
+		defer wg.Done()
+		var addresses, in, zero argon2Block
+		dataIndependent := mode == argon2i || (mode == argon2id && n == 0 && slice < argon2SyncPoints/2)
+		if dataIndependent {
+			in[0] = uint64(n)
+			in[1] = uint64(lane)
+			in[2] = uint64(slice)
+			in[3] = uint64(memory)
+			in[4] = uint64(time)
+			in[5] = uint64(mode)
+		}
+
+		index := uint32(0)
+		if n == 0 && slice == 0 {
+			// the first two blocks are already computed
+			index = 2
+			if dataIndependent {
+				in[6]++
+				argon2ProcessBlock(&addresses, &in, &zero, false)
+				argon2ProcessBlock(&addresses, &addresses, &zero, false)
+			}
+		}
+
+		// version 1.0 overwrites blocks in later passes, version 1.3 xors the new block into the old one
+		xor := version != 0x10 && n > 0
+
+		offset := lane*lanes + slice*segments + index
+		var random uint64
+		for index < segments {
+			prev := offset - 1
+			if index == 0 && slice == 0 {
+				// last block in lane
+				prev += lanes
+			}
+			if dataIndependent {
+				if index%argon2BlockLength == 0 {
+					in[6]++
+					argon2ProcessBlock(&addresses, &in, &zero, false)
+					argon2ProcessBlock(&addresses, &addresses, &zero, false)
+				}
+				random = addresses[index%argon2BlockLength]
+			} else {
+				random = blocks[prev][0]
+			}
+			newOffset := argon2IndexAlpha(random, lanes, segments, threads, n, slice, lane, index)
+			argon2ProcessBlock(&blocks[offset], &blocks[prev], &blocks[newOffset], xor)
+			index, offset = index+1, offset+1
+		}
+	}
+
+	for n := uint32(0); n < time; n++ {
+		for slice := uint32(0); slice < argon2SyncPoints; slice++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var wg sync.WaitGroup
+			for lane := uint32(0); lane < threads; lane++ {
+				wg.Add(1)
+				go processSegment(n, slice, lane, &wg)
+			}
+			wg.Wait()
+		}
+	}
+	return nil
+}
+
+func argon2ExtractKey(blocks []argon2Block, memory, threads, keyLen uint32) []byte {
+	lanes := memory / threads
+	for lane := uint32(0); lane < threads-1; lane++ {
+		for i, v := range blocks[(lane*lanes)+lanes-1] {
+			blocks[memory-1][i] ^= v
+		}
+	}
+
+	var block [1024]byte
+	for i, v := range blocks[memory-1] {
+		binary.LittleEndian.PutUint64(block[i*8:], v)
+	}
+	key := make([]byte, keyLen)
+	argon2Blake2bHash(key, block[:])
+	return key
+}
+
+func argon2IndexAlpha(rand uint64, lanes, segments, threads, n, slice, lane, index uint32) uint32 {
+	refLane := uint32(rand>>32) % threads
+	if n == 0 && slice == 0 {
+		refLane = lane
+	}
+	m, s := 3*segments, ((slice+1)%argon2SyncPoints)*segments
+	if lane == refLane {
+		m += index
+	}
+	if n == 0 {
+		m, s = slice*segments, 0
+		if slice == 0 || lane == refLane {
+			m += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		m--
+	}
+	return argon2Phi(rand, uint64(m), uint64(s), refLane, lanes)
+}
+
+func argon2Phi(rand, m, s uint64, lane, lanes uint32) uint32 {
+	p := rand & 0xFFFFFFFF
+	p = (p * p) >> 32
+	p = (p * m) >> 32
+	return lane*lanes + uint32((s+m-(p+1))%uint64(lanes))
+}
+
+// argon2ProcessBlock computes the compression function G(in1, in2) and stores the result in out, if xor is true
+// the result is xored into out.
+func argon2ProcessBlock(out, in1, in2 *argon2Block, xor bool) {
+	var t argon2Block
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+	// apply the permutation on the rows
+	for i := 0; i < argon2BlockLength; i += 16 {
+		argon2Blamka(
+			&t[i+0], &t[i+1], &t[i+2], &t[i+3],
+			&t[i+4], &t[i+5], &t[i+6], &t[i+7],
+			&t[i+8], &t[i+9], &t[i+10], &t[i+11],
+			&t[i+12], &t[i+13], &t[i+14], &t[i+15],
+		)
+	}
+	// and on the columns
+	for i := 0; i < argon2BlockLength/8; i += 2 {
+		argon2Blamka(
+			&t[i], &t[i+1], &t[16+i], &t[16+i+1],
+			&t[32+i], &t[32+i+1], &t[48+i], &t[48+i+1],
+			&t[64+i], &t[64+i+1], &t[80+i], &t[80+i+1],
+			&t[96+i], &t[96+i+1], &t[112+i], &t[112+i+1],
+		)
+	}
+	if xor {
+		for i := range t {
+			out[i] ^= in1[i] ^ in2[i] ^ t[i]
+		}
+	} else {
+		for i := range t {
+			out[i] = in1[i] ^ in2[i] ^ t[i]
+		}
+	}
+}
+
+// argon2Blamka is the permutation P, a blake2b round with the multiplication of the lower 32 bits added.
+func argon2Blamka(v00, v01, v02, v03, v04, v05, v06, v07, v08, v09, v10, v11, v12, v13, v14, v15 *uint64) {
+	argon2G(v00, v04, v08, v12)
+	argon2G(v01, v05, v09, v13)
+	argon2G(v02, v06, v10, v14)
+	argon2G(v03, v07, v11, v15)
+
+	argon2G(v00, v05, v10, v15)
+	argon2G(v01, v06, v11, v12)
+	argon2G(v02, v07, v08, v13)
+	argon2G(v03, v04, v09, v14)
+}
+
+func argon2G(a, b, c, d *uint64) {
+	va, vb, vc, vd := *a, *b, *c, *d
+
+	va += vb + 2*uint64(uint32(va))*uint64(uint32(vb))
+	vd ^= va
+	vd = vd>>32 | vd<<32
+	vc += vd + 2*uint64(uint32(vc))*uint64(uint32(vd))
+	vb ^= vc
+	vb = vb>>24 | vb<<40
+
+	va += vb + 2*uint64(uint32(va))*uint64(uint32(vb))
+	vd ^= va
+	vd = vd>>16 | vd<<48
+	vc += vd + 2*uint64(uint32(vc))*uint64(uint32(vd))
+	vb ^= vc
+	vb = vb<<1 | vb>>63
+
+	*a, *b, *c, *d = va, vb, vc, vd
+}
+
+// argon2Blake2bHash is the variable length hash function H'.
+func argon2Blake2bHash(out []byte, in []byte) {
+	var b2 hash.Hash
+	if n := len(out); n < blake2b.Size {
+		b2, _ = blake2b.New(n, nil)
+	} else {
+		b2, _ = blake2b.New512(nil)
+	}
+
+	var buffer [blake2b.Size]byte
+	binary.LittleEndian.PutUint32(buffer[:4], uint32(len(out)))
+	b2.Write(buffer[:4])
+	b2.Write(in)
+
+	if len(out) <= blake2b.Size {
+		b2.Sum(out[:0])
+		return
+	}
+
+	outLen := len(out)
+	b2.Sum(buffer[:0])
+	b2.Reset()
+	copy(out, buffer[:32])
+	out = out[32:]
+	for len(out) > blake2b.Size {
+		b2.Write(buffer[:])
+		b2.Sum(buffer[:0])
+		copy(out, buffer[:32])
+		out = out[32:]
+		b2.Reset()
+	}
+
+	if outLen%blake2b.Size > 0 {
+		// ⌈τ/32⌉-2
+		r := ((outLen + 31) / 32) - 2
+		b2, _ = blake2b.New(outLen-32*r, nil)
+	}
+	b2.Write(buffer[:])
+	b2.Sum(out[:0])
+}
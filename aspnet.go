@@ -0,0 +1,194 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ASP.NET Core Identity hashes (PasswordHasher in Microsoft.AspNetCore.Identity) are stored as a single standard
+// base64 blob. The first byte is a format marker:
+//
+// Version 2: 0x00 | salt (16 bytes) | subkey (32 bytes), PBKDF2 with HMAC-SHA1 and 1000 iterations.
+//
+// Version 3: 0x01 | prf (uint32) | iterations (uint32) | salt length (uint32) | salt | subkey,
+// all integers are big endian. The prf is 0 (HMAC-SHA1), 1 (HMAC-SHA256) or 2 (HMAC-SHA512).
+
+type ASPNetIdentityVersion byte
+
+const (
+	ASPNetIdentityV2 ASPNetIdentityVersion = 0x00
+	ASPNetIdentityV3 ASPNetIdentityVersion = 0x01
+)
+
+const (
+	aspNetV2Iterations   = 1000
+	aspNetV2SaltLength   = 16
+	aspNetV2SubkeyLength = 32
+	aspNetV3HeaderLength = 13
+	// the minimum salt / subkey length accepted by ASP.NET for version 3 hashes
+	aspNetV3MinLength = 16
+)
+
+var aspNetPRFs = []string{
+	"pbkdf2-sha1",
+	"pbkdf2-sha256",
+	"pbkdf2-sha512",
+}
+
+var (
+	ErrInvalidASPNetIdentityHash = errors.New("invalid ASP.NET Identity hash")
+)
+
+func newInvalidASPNetIdentityHashError(message string) error {
+	return fmt.Errorf("%s: %w", message, ErrInvalidASPNetIdentityHash)
+}
+
+// DecodeASPNetIdentity decodes a version 2 or version 3 ASP.NET Core Identity hash.
+//
+// The result can be encoded to a phc string with PBKDF2PHC.Encode and verified with PBKDF2PHC.Verify.
+func DecodeASPNetIdentity(s string) (*PBKDF2PHC, error) {
+	if limitErr := DefaultLimits.CheckString(s); limitErr != nil {
+		return nil, limitErr
+	}
+	blob, decodeErr := base64.StdEncoding.DecodeString(s)
+	if decodeErr != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidASPNetIdentityHash, newBase64DecodeErrorWrapper(decodeErr).Error())
+	}
+	if len(blob) == 0 {
+		return nil, newInvalidASPNetIdentityHashError("empty hash")
+	}
+	var res *PBKDF2PHC
+	var err error
+	switch ASPNetIdentityVersion(blob[0]) {
+	case ASPNetIdentityV2:
+		res, err = decodeASPNetIdentityV2(blob)
+	case ASPNetIdentityV3:
+		res, err = decodeASPNetIdentityV3(blob)
+	default:
+		return nil, newInvalidASPNetIdentityHashError(fmt.Sprintf("unknown format marker 0x%02x", blob[0]))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if limitErr := DefaultLimits.CheckPBKDF2(res); limitErr != nil {
+		return nil, limitErr
+	}
+	return res, nil
+}
+
+func newASPNetPBKDF2PHC(variant string, iterations int, salt, subkey []byte) *PBKDF2PHC {
+	return &PBKDF2PHC{
+		Variant:    variant,
+		Iterations: iterations,
+		Salt:       salt,
+		SaltString: string(Base64Encode(salt)),
+		Hash:       subkey,
+		HashString: string(Base64Encode(subkey)),
+	}
+}
+
+func decodeASPNetIdentityV2(blob []byte) (*PBKDF2PHC, error) {
+	if len(blob) != 1+aspNetV2SaltLength+aspNetV2SubkeyLength {
+		return nil, newInvalidASPNetIdentityHashError(fmt.Sprintf("version 2 hash must have length %d, got %d",
+			1+aspNetV2SaltLength+aspNetV2SubkeyLength, len(blob)))
+	}
+	salt := blob[1 : 1+aspNetV2SaltLength]
+	subkey := blob[1+aspNetV2SaltLength:]
+	return newASPNetPBKDF2PHC("pbkdf2-sha1", aspNetV2Iterations, salt, subkey), nil
+}
+
+func decodeASPNetIdentityV3(blob []byte) (*PBKDF2PHC, error) {
+	if len(blob) < aspNetV3HeaderLength {
+		return nil, newInvalidASPNetIdentityHashError("version 3 hash is too short")
+	}
+	prf := binary.BigEndian.Uint32(blob[1:5])
+	iterations := binary.BigEndian.Uint32(blob[5:9])
+	saltLength := binary.BigEndian.Uint32(blob[9:13])
+	if uint64(prf) >= uint64(len(aspNetPRFs)) {
+		return nil, newInvalidASPNetIdentityHashError(fmt.Sprintf("unknown prf %d", prf))
+	}
+	if iterations < 1 || iterations > uint32(maxInt32) {
+		return nil, newInvalidASPNetIdentityHashError(fmt.Sprintf("invalid iteration count %d", iterations))
+	}
+	if saltLength < aspNetV3MinLength {
+		return nil, newInvalidASPNetIdentityHashError(fmt.Sprintf("salt must have at least %d bytes, got %d",
+			aspNetV3MinLength, saltLength))
+	}
+	rest := blob[aspNetV3HeaderLength:]
+	if uint64(len(rest)) < uint64(saltLength)+aspNetV3MinLength {
+		return nil, newInvalidASPNetIdentityHashError("version 3 hash is too short for salt and subkey")
+	}
+	salt := rest[:saltLength]
+	subkey := rest[saltLength:]
+	return newASPNetPBKDF2PHC(aspNetPRFs[prf], int(iterations), salt, subkey), nil
+}
+
+// EncodeASPNetIdentity encodes a PBKDF2 hash as ASP.NET Core Identity hash of the given version.
+//
+// Version 2 can only encode HMAC-SHA1 hashes with 1000 iterations, a 16 byte salt and a 32 byte hash.
+func EncodeASPNetIdentity(phc *PBKDF2PHC, version ASPNetIdentityVersion) (string, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return "", err
+	}
+	var blob []byte
+	switch version {
+	case ASPNetIdentityV2:
+		if phc.Variant != "pbkdf2-sha1" || phc.Iterations != aspNetV2Iterations ||
+			len(phc.Salt) != aspNetV2SaltLength || len(phc.Hash) != aspNetV2SubkeyLength {
+			return "", newInvalidASPNetIdentityHashError("hash can't be represented as version 2 hash")
+		}
+		blob = make([]byte, 0, 1+aspNetV2SaltLength+aspNetV2SubkeyLength)
+		blob = append(blob, byte(ASPNetIdentityV2))
+	case ASPNetIdentityV3:
+		prf := -1
+		for i, candidate := range aspNetPRFs {
+			if candidate == phc.Variant {
+				prf = i
+				break
+			}
+		}
+		// should not happen after validation, but just to be sure
+		if prf < 0 {
+			return "", NewMismatchedFunctionNameError(phc.Variant, aspNetPRFs...)
+		}
+		if len(phc.Salt) < aspNetV3MinLength || len(phc.Hash) < aspNetV3MinLength {
+			return "", newInvalidASPNetIdentityHashError(fmt.Sprintf("salt and hash must have at least %d bytes",
+				aspNetV3MinLength))
+		}
+		blob = make([]byte, aspNetV3HeaderLength, aspNetV3HeaderLength+len(phc.Salt)+len(phc.Hash))
+		blob[0] = byte(ASPNetIdentityV3)
+		binary.BigEndian.PutUint32(blob[1:5], uint32(prf))
+		binary.BigEndian.PutUint32(blob[5:9], uint32(phc.Iterations))
+		binary.BigEndian.PutUint32(blob[9:13], uint32(len(phc.Salt)))
+	default:
+		return "", newInvalidASPNetIdentityHashError(fmt.Sprintf("unknown version 0x%02x", byte(version)))
+	}
+	blob = append(blob, phc.Salt...)
+	blob = append(blob, phc.Hash...)
+	return base64.StdEncoding.EncodeToString(blob), nil
+}
+
+// VerifyASPNetIdentity decodes the ASP.NET Core Identity hash and verifies the password against it.
+func VerifyASPNetIdentity(s string, password []byte) (bool, error) {
+	phc, err := DecodeASPNetIdentity(s)
+	if err != nil {
+		return false, err
+	}
+	return phc.Verify(password)
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt hashes are not phc strings but use the modular crypt format: $2b$<cost>$<22 chars salt><31 chars hash>.
+
+var BcryptVariants = []string{
+	"2a",
+	"2b",
+	"2y",
+}
+
+const (
+	bcryptSaltLength    = 22
+	bcryptHashLength    = 31
+	bcryptEncodedLength = 60
+	bcryptAlphabet      = "./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+var (
+	ErrInvalidBcryptHash = errors.New("invalid bcrypt hash")
+)
+
+// BcryptHash is a bcrypt hash in modular crypt format.
+// Salt and hash are kept in the bcrypt base64 encoding.
+type BcryptHash struct {
+	Variant string
+	Cost    int
+	Salt    string
+	Hash    string
+}
+
+func (h *BcryptHash) ValidateParameters() error {
+	if !isValidBcryptVariant(h.Variant) {
+		return NewMismatchedFunctionNameError(h.Variant, BcryptVariants...)
+	}
+	if h.Cost < bcrypt.MinCost || h.Cost > bcrypt.MaxCost {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("must be between %d <= cost <= %d, got %d",
+			bcrypt.MinCost, bcrypt.MaxCost, h.Cost), "cost", nil)
+	}
+	if len(h.Salt) != bcryptSaltLength {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("salt must have length %d", bcryptSaltLength), "salt", nil)
+	}
+	return nil
+}
+
+func isValidBcryptVariant(v string) bool {
+	for _, candidate := range BcryptVariants {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+func newInvalidBcryptHashError(message string) error {
+	return fmt.Errorf("%s: %w", message, ErrInvalidBcryptHash)
+}
+
+func DecodeBcrypt(s string) (*BcryptHash, error) {
+	return decodeBcrypt(s, DefaultLimits)
+}
+
+func decodeBcrypt(s string, limits *Limits) (*BcryptHash, error) {
+	if limitErr := limits.CheckString(s); limitErr != nil {
+		return nil, limitErr
+	}
+	if len(s) != bcryptEncodedLength {
+		return nil, newInvalidBcryptHashError(fmt.Sprintf("hash must have length %d, got %d", bcryptEncodedLength, len(s)))
+	}
+	// the format is fixed: $2b$10$...
+	if s[0] != '$' || s[3] != '$' || s[6] != '$' {
+		return nil, newInvalidBcryptHashError("hash must be of the form $<variant>$<cost>$<salt and hash>")
+	}
+	variant := s[1:3]
+	if !isValidBcryptVariant(variant) {
+		return nil, NewMismatchedFunctionNameError(variant, BcryptVariants...)
+	}
+	cost, costErr := strconv.Atoi(s[4:6])
+	if costErr != nil {
+		return nil, wrapParameterValueErrorToPHCError("can't parse as integer", "cost", costErr)
+	}
+	rest := s[7:]
+	if onlyValidRunes, invalidRune := validateRuneFunc(isValidBcryptRune, rest); !onlyValidRunes {
+		return nil, newInvalidBcryptHashError(fmt.Sprintf("invalid character \"%s\"", string(invalidRune)))
+	}
+	res := &BcryptHash{
+		Variant: variant,
+		Cost:    cost,
+		Salt:    rest[:bcryptSaltLength],
+		Hash:    rest[bcryptSaltLength:],
+	}
+	if err := res.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	if limitErr := limits.CheckBcrypt(res); limitErr != nil {
+		return nil, limitErr
+	}
+	return res, nil
+}
+
+func isValidBcryptRune(r rune) bool {
+	return strings.ContainsRune(bcryptAlphabet, r)
+}
+
+// Encode returns the bcrypt string in modular crypt format.
+// If no hash is set the result contains only the variant, cost and salt.
+func (h *BcryptHash) Encode() (string, error) {
+	if err := h.ValidateParameters(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$%s$%02d$%s%s", h.Variant, h.Cost, h.Salt, h.Hash), nil
+}
+
+// Verify checks if the password matches the hash.
+func (h *BcryptHash) Verify(password []byte) (bool, error) {
+	return h.verifyLimits(context.Background(), password, DefaultLimits)
+}
+
+func (h *BcryptHash) verifyLimits(_ context.Context, password []byte, limits *Limits) (bool, error) {
+	if len(h.Hash) != bcryptHashLength {
+		return false, NewPHCError("can't verify password", ErrMissingHash)
+	}
+	encoded, encodeErr := h.Encode()
+	if encodeErr != nil {
+		return false, encodeErr
+	}
+	if limitErr := limits.CheckBcrypt(h); limitErr != nil {
+		return false, limitErr
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), password)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, err
+	}
+}
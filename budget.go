@@ -0,0 +1,255 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrMemoryBudgetExceeded = errors.New("memory budget exceeded")
+)
+
+// EstimateMemory returns the estimated memory in bytes required to compute h.
+//
+// h can be a PasswordHash or a PasswordHasher implemented in this package, wrapped hashes (LDAP, Spring, envelopes) and
+// presets are unwrapped. For argon2 the estimate is m KiB, for scrypt 128 * r * (N + p) bytes.
+// For all other hashes the memory is negligible and 0 is returned.
+func EstimateMemory(h interface{}) uint64 {
+	switch v := h.(type) {
+	case *Argon2PHC:
+		return uint64(v.M) * 1024
+	case *ScryptPHC:
+		return scryptMemory(v.Cost, v.BlockSize, v.Parallelism)
+	case *FirebaseScryptPHC:
+		if v.MemCost < 0 || v.MemCost > 62 {
+			return ^uint64(0)
+		}
+		return scryptMemory(1<<uint(v.MemCost), v.Rounds, 1)
+	case *OnionPHC:
+		return EstimateMemory(v.Outer)
+	case *LDAPPassword:
+		return EstimateMemory(v.Hash)
+	case *SpringPasswordHash:
+		return EstimateMemory(v.Hash)
+	case *EnvelopeHash:
+		return EstimateMemory(v.Hash)
+	case *Preset:
+		if v.Argon2 != nil {
+			return EstimateMemory(v.Argon2)
+		}
+		return EstimateMemory(v.Scrypt)
+	default:
+		return 0
+	}
+}
+
+// BudgetMode describes what a MemoryBudget does if not enough memory is available.
+type BudgetMode int
+
+const (
+	// BudgetQueue waits until enough memory is released (or the context is done)
+	BudgetQueue BudgetMode = iota
+	// BudgetReject returns ErrMemoryBudgetExceeded immediately
+	BudgetReject
+)
+
+type budgetWaiter struct {
+	n     uint64
+	ready chan struct{}
+}
+
+// MemoryBudget is a weighted semaphore that limits the memory used by concurrent hash computations.
+//
+// Waiting requests are admitted in FIFO order. A MemoryBudget is safe for concurrent use.
+type MemoryBudget struct {
+	capacity uint64
+	mode     BudgetMode
+	mutex    sync.Mutex
+	used     uint64
+	waiters  list.List
+}
+
+// NewMemoryBudget returns a budget with the given capacity in bytes.
+func NewMemoryBudget(capacity uint64, mode BudgetMode) *MemoryBudget {
+	return &MemoryBudget{
+		capacity: capacity,
+		mode:     mode,
+	}
+}
+
+// Acquire reserves n bytes, it must be released with Release once the computation is done.
+//
+// If n exceeds the capacity or if the budget is in BudgetReject mode and not enough memory is available
+// ErrMemoryBudgetExceeded is returned. In BudgetQueue mode Acquire blocks until the memory is available or ctx is
+// done, in the latter case ctx.Err() is returned. Nothing is reserved if ctx is already done.
+func (budget *MemoryBudget) Acquire(ctx context.Context, n uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	budget.mutex.Lock()
+	if n > budget.capacity {
+		budget.mutex.Unlock()
+		return fmt.Errorf("requested %d bytes, capacity is %d: %w", n, budget.capacity, ErrMemoryBudgetExceeded)
+	}
+	if budget.capacity-budget.used >= n && budget.waiters.Len() == 0 {
+		budget.used += n
+		budget.mutex.Unlock()
+		return nil
+	}
+	if budget.mode == BudgetReject {
+		budget.mutex.Unlock()
+		return fmt.Errorf("requested %d bytes, %d of %d in use: %w", n, budget.used, budget.capacity,
+			ErrMemoryBudgetExceeded)
+	}
+	waiter := &budgetWaiter{n: n, ready: make(chan struct{})}
+	elem := budget.waiters.PushBack(waiter)
+	budget.mutex.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+		budget.mutex.Lock()
+		select {
+		case <-waiter.ready:
+			// acquired after the context was done, give it back
+			budget.used -= n
+			budget.notifyWaiters()
+		default:
+			isFront := budget.waiters.Front() == elem
+			budget.waiters.Remove(elem)
+			// the removed waiter might have blocked the ones behind it
+			if isFront {
+				budget.notifyWaiters()
+			}
+		}
+		budget.mutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Release releases n bytes acquired with Acquire.
+func (budget *MemoryBudget) Release(n uint64) {
+	budget.mutex.Lock()
+	if n > budget.used {
+		budget.mutex.Unlock()
+		panic("gophc: released more memory than acquired")
+	}
+	budget.used -= n
+	budget.notifyWaiters()
+	budget.mutex.Unlock()
+}
+
+// Capacity returns the capacity in bytes.
+func (budget *MemoryBudget) Capacity() uint64 {
+	return budget.capacity
+}
+
+// InUse returns the number of bytes currently acquired.
+func (budget *MemoryBudget) InUse() uint64 {
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	return budget.used
+}
+
+// notifyWaiters admits waiters in FIFO order as long as there is enough memory, the mutex must be held.
+func (budget *MemoryBudget) notifyWaiters() {
+	for {
+		front := budget.waiters.Front()
+		if front == nil {
+			return
+		}
+		waiter := front.Value.(*budgetWaiter)
+		if budget.capacity-budget.used < waiter.n {
+			return
+		}
+		budget.used += waiter.n
+		budget.waiters.Remove(front)
+		close(waiter.ready)
+	}
+}
+
+// BoundedVerifier computes and verifies hashes, the memory of each computation is acquired from Budget first.
+//
+// The context is used to wait for the budget and is passed to the hash computation, see VerifyContext and
+// NewHashContext. The memory is released as soon as the computation returns.
+type BoundedVerifier struct {
+	// Registry is used to decode hashes, if nil DefaultRegistry is used
+	Registry *Registry
+	Budget   *MemoryBudget
+}
+
+func NewBoundedVerifier(registry *Registry, budget *MemoryBudget) *BoundedVerifier {
+	return &BoundedVerifier{
+		Registry: registry,
+		Budget:   budget,
+	}
+}
+
+func (verifier *BoundedVerifier) registry() *Registry {
+	if verifier.Registry == nil {
+		return DefaultRegistry
+	}
+	return verifier.Registry
+}
+
+// run calls f after the estimated memory of h has been acquired.
+func (verifier *BoundedVerifier) run(ctx context.Context, h interface{}, f func() error) error {
+	n := EstimateMemory(h)
+	if n == 0 {
+		return f()
+	}
+	if err := verifier.Budget.Acquire(ctx, n); err != nil {
+		return err
+	}
+	defer verifier.Budget.Release(n)
+	return f()
+}
+
+// Verify decodes s with the registry and verifies the password.
+func (verifier *BoundedVerifier) Verify(ctx context.Context, s string, password []byte) (bool, error) {
+	h, err := verifier.registry().Decode(s)
+	if err != nil {
+		return false, err
+	}
+	return verifier.VerifyHash(ctx, h, password)
+}
+
+// VerifyHash verifies the password against an already decoded hash.
+func (verifier *BoundedVerifier) VerifyHash(ctx context.Context, h PasswordHash, password []byte) (bool, error) {
+	var res bool
+	err := verifier.run(ctx, h, func() error {
+		var verifyErr error
+		res, verifyErr = verifyWithLimits(ctx, h, password, verifier.registry().limits())
+		return verifyErr
+	})
+	return res, err
+}
+
+// NewHash computes a new hash with hasher.
+func (verifier *BoundedVerifier) NewHash(ctx context.Context, hasher PasswordHasher, password []byte) (PasswordHash, error) {
+	var res PasswordHash
+	err := verifier.run(ctx, hasher, func() error {
+		var hashErr error
+		res, hashErr = NewHashContext(ctx, hasher, password)
+		return hashErr
+	})
+	return res, err
+}
@@ -0,0 +1,275 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+var (
+	ErrInvalidCalibrationOptions = errors.New("invalid calibration options")
+)
+
+const (
+	// the memory (in KiB) the argon2 calibration starts with
+	argon2CalibrationStartMemory = 8 * 1024
+	// the ln the scrypt calibration starts with
+	scryptCalibrationStartLn = 10
+	// the block size used for scrypt, the default of the scrypt paper
+	scryptCalibrationBlockSize = 8
+	// the maximum number of iterations if no limit is given in DefaultLimits
+	calibrationMaxIterations = 1024
+)
+
+// these are only used to benchmark, the timings don't depend on the values
+var (
+	calibrationPassword = []byte("password")
+	calibrationSalt     = []byte("calibrationsalt!")
+)
+
+// CalibrationOptions describe the target of a calibration.
+type CalibrationOptions struct {
+	// TargetDuration is the desired duration of a single hash computation, must be > 0
+	TargetDuration time.Duration
+	// MaxMemory is the maximum memory in bytes a single hash computation may use, must be > 0
+	MaxMemory uint64
+	// Cores is the number of cores to use, if 0 runtime.NumCPU() is used
+	Cores int
+	// Variant is the argon2 variant, if empty argon2id is used
+	Variant string
+}
+
+func (opts *CalibrationOptions) validate() error {
+	if opts.TargetDuration <= 0 {
+		return fmt.Errorf("target duration must be > 0, got %s: %w", opts.TargetDuration, ErrInvalidCalibrationOptions)
+	}
+	if opts.MaxMemory == 0 {
+		return fmt.Errorf("max memory must be > 0: %w", ErrInvalidCalibrationOptions)
+	}
+	if opts.Cores < 0 {
+		return fmt.Errorf("cores must be >= 0, got %d: %w", opts.Cores, ErrInvalidCalibrationOptions)
+	}
+	return nil
+}
+
+func (opts *CalibrationOptions) cores() int {
+	if opts.Cores == 0 {
+		return runtime.NumCPU()
+	}
+	return opts.Cores
+}
+
+// CalibrationMeasurement is a single benchmark run during calibration.
+type CalibrationMeasurement struct {
+	// Parameters are the parameters in the phc format, for example "m=65536,t=3,p=4"
+	Parameters string
+	Duration   time.Duration
+}
+
+// CalibrationResult contains the measured timings of a calibration.
+type CalibrationResult struct {
+	// Duration is the measured duration of the returned parameters
+	Duration time.Duration
+	// Measurements contains all benchmarks in the order in which they were run
+	Measurements []CalibrationMeasurement
+}
+
+func (res *CalibrationResult) add(parameters string, d time.Duration) {
+	res.Measurements = append(res.Measurements, CalibrationMeasurement{Parameters: parameters, Duration: d})
+	res.Duration = d
+}
+
+func (phc *Argon2PHC) benchmark(res *CalibrationResult) (time.Duration, error) {
+	start := time.Now()
+	if _, err := Argon2Key(phc.Variant, phc.Version, calibrationPassword, calibrationSalt, nil, nil,
+		phc.T, phc.M, phc.P, 32); err != nil {
+		return 0, err
+	}
+	d := time.Since(start)
+	res.add(fmt.Sprintf("m=%d,t=%d,p=%d", phc.M, phc.T, phc.P), d)
+	return d, nil
+}
+
+// CalibrateArgon2 benchmarks argon2 on the local machine and returns parameters that take at most
+// opts.TargetDuration (as far as possible) and use at most opts.MaxMemory bytes.
+//
+// As recommended by RFC 9106 the memory is increased first, once the memory ceiling is reached the number of
+// iterations is increased. The parallelism is set to the number of cores.
+// The parameters are also restricted by DefaultLimits.
+//
+// The returned instance contains no salt and hash, it passes ValidateParameters.
+func CalibrateArgon2(opts CalibrationOptions) (*Argon2PHC, *CalibrationResult, error) {
+	if err := opts.validate(); err != nil {
+		return nil, nil, err
+	}
+	variant := opts.Variant
+	if variant == "" {
+		variant = "argon2id"
+	}
+	maxMemory := opts.MaxMemory / 1024
+	if maxMemory > maxUint32 {
+		maxMemory = maxUint32
+	}
+	maxIterations := uint32(calibrationMaxIterations)
+	p := uint64(opts.cores())
+	if p > Argon2MaxParallelism {
+		p = Argon2MaxParallelism
+	}
+	if DefaultLimits != nil {
+		if exceeds(maxMemory, uint64(DefaultLimits.Argon2.MaxMemory)) {
+			maxMemory = uint64(DefaultLimits.Argon2.MaxMemory)
+		}
+		if DefaultLimits.Argon2.MaxIterations > 0 {
+			maxIterations = DefaultLimits.Argon2.MaxIterations
+		}
+		if exceeds(p, uint64(DefaultLimits.Argon2.MaxParallelism)) {
+			p = uint64(DefaultLimits.Argon2.MaxParallelism)
+		}
+	}
+	minMemory := argon2MinMemoryPerLane * p
+	if maxMemory < minMemory {
+		return nil, nil, fmt.Errorf("max memory must be at least %d KiB for %d lanes: %w", minMemory, p,
+			ErrInvalidCalibrationOptions)
+	}
+	m := uint64(argon2CalibrationStartMemory)
+	if m < minMemory {
+		m = minMemory
+	}
+	if m > maxMemory {
+		m = maxMemory
+	}
+	phc := &Argon2PHC{
+		Variant: variant,
+		Version: 0x13,
+		M:       uint32(m),
+		T:       1,
+		P:       uint32(p),
+	}
+	if err := phc.ValidateParameters(); err != nil {
+		return nil, nil, err
+	}
+	res := &CalibrationResult{}
+	d, err := phc.benchmark(res)
+	if err != nil {
+		return nil, nil, err
+	}
+	// double the memory as long as the target is (probably) not exceeded
+	for uint64(phc.M) < maxMemory && 2*d <= opts.TargetDuration {
+		next := 2 * uint64(phc.M)
+		if next > maxMemory {
+			next = maxMemory
+		}
+		phc.M = uint32(next)
+		if d, err = phc.benchmark(res); err != nil {
+			return nil, nil, err
+		}
+	}
+	// memory ceiling reached: increase the iterations, the duration is linear in t
+	if uint64(phc.M) == maxMemory && d > 0 && 2*d <= opts.TargetDuration {
+		t := uint64(opts.TargetDuration / d)
+		if t > uint64(maxIterations) {
+			t = uint64(maxIterations)
+		}
+		phc.T = uint32(t)
+		if d, err = phc.benchmark(res); err != nil {
+			return nil, nil, err
+		}
+		for d > opts.TargetDuration && phc.T > 1 {
+			phc.T--
+			if d, err = phc.benchmark(res); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return phc, res, nil
+}
+
+func (phc *ScryptPHC) benchmark(ln int, res *CalibrationResult) (time.Duration, error) {
+	start := time.Now()
+	if _, err := ScryptKeyContext(context.Background(), calibrationPassword, calibrationSalt,
+		phc.Cost, phc.BlockSize, phc.Parallelism, 32); err != nil {
+		return 0, err
+	}
+	d := time.Since(start)
+	res.add(fmt.Sprintf("ln=%d,r=%d,p=%d", ln, phc.BlockSize, phc.Parallelism), d)
+	return d, nil
+}
+
+// CalibrateScrypt benchmarks scrypt on the local machine and returns parameters that take at most
+// opts.TargetDuration (as far as possible) and use at most opts.MaxMemory bytes (estimated as 128 * N * r).
+//
+// The block size is fixed to 8. The cost N is increased first, once the memory ceiling is reached the
+// parallelism p is increased (p is computed sequentially, so opts.Cores is ignored).
+// The parameters are also restricted by DefaultLimits.
+//
+// The returned instance contains no salt and hash, it passes ValidateParameters.
+func CalibrateScrypt(opts CalibrationOptions) (*ScryptPHC, *CalibrationResult, error) {
+	if err := opts.validate(); err != nil {
+		return nil, nil, err
+	}
+	fits := func(ln, p int) bool {
+		candidate := &ScryptPHC{Cost: 1 << uint(ln), BlockSize: scryptCalibrationBlockSize, Parallelism: p}
+		if candidate.ValidateParameters() != nil {
+			return false
+		}
+		if 128*uint64(candidate.Cost)*uint64(candidate.BlockSize) > opts.MaxMemory {
+			return false
+		}
+		return DefaultLimits.CheckScrypt(candidate) == nil
+	}
+	ln := scryptCalibrationStartLn
+	for ln > 1 && !fits(ln, 1) {
+		ln--
+	}
+	if !fits(ln, 1) {
+		return nil, nil, fmt.Errorf("max memory %d is too small for scrypt: %w", opts.MaxMemory,
+			ErrInvalidCalibrationOptions)
+	}
+	phc := &ScryptPHC{Cost: 1 << uint(ln), BlockSize: scryptCalibrationBlockSize, Parallelism: 1}
+	res := &CalibrationResult{}
+	d, err := phc.benchmark(ln, res)
+	if err != nil {
+		return nil, nil, err
+	}
+	for fits(ln+1, 1) && 2*d <= opts.TargetDuration {
+		ln++
+		phc.Cost = 1 << uint(ln)
+		if d, err = phc.benchmark(ln, res); err != nil {
+			return nil, nil, err
+		}
+	}
+	// memory ceiling reached: increase p, the duration is linear in p
+	if !fits(ln+1, 1) && d > 0 && 2*d <= opts.TargetDuration {
+		p := int(opts.TargetDuration / d)
+		for p > 1 && !fits(ln, p) {
+			p--
+		}
+		phc.Parallelism = p
+		if d, err = phc.benchmark(ln, res); err != nil {
+			return nil, nil, err
+		}
+		for d > opts.TargetDuration && phc.Parallelism > 1 {
+			phc.Parallelism--
+			if d, err = phc.benchmark(ln, res); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return phc, res, nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// DescriptionParameter is a parameter of a Description, Value is given in human units (for example "64 MiB").
+type DescriptionParameter struct {
+	Name  string
+	Value string
+}
+
+// Description is a summary of a hash that is safe to log: It contains the parameters, but only the lengths and
+// fingerprints of the salt and hash.
+//
+// A fingerprint is the first four bytes of the SHA-256 of the value (hex encoded), it can be used to tell two hashes
+// apart but reveals nothing useful about the hash.
+type Description struct {
+	Algorithm       string
+	Parameters      []DescriptionParameter
+	SaltLength      int
+	SaltFingerprint string
+	HashLength      int
+	HashFingerprint string
+}
+
+// String returns a summary like "argon2id v=19, m=64 MiB, t=3, p=4, 16-byte salt, 32-byte hash".
+// The fingerprints are not included, see Format.
+func (d *Description) String() string {
+	return d.format(false)
+}
+
+// Format implements fmt.Formatter, %+v includes the fingerprints of the salt and hash.
+func (d *Description) Format(f fmt.State, verb rune) {
+	s := d.format(verb == 'v' && f.Flag('+'))
+	if verb == 'q' {
+		s = strconv.Quote(s)
+	}
+	_, _ = io.WriteString(f, s)
+}
+
+func (d *Description) format(fingerprints bool) string {
+	parts := make([]string, 0, len(d.Parameters)+2)
+	for _, param := range d.Parameters {
+		parts = append(parts, param.Name+"="+param.Value)
+	}
+	salt := fmt.Sprintf("%d-byte salt", d.SaltLength)
+	hash := fmt.Sprintf("%d-byte hash", d.HashLength)
+	if fingerprints {
+		if d.SaltFingerprint != "" {
+			salt += " " + d.SaltFingerprint
+		}
+		if d.HashFingerprint != "" {
+			hash += " " + d.HashFingerprint
+		}
+	}
+	parts = append(parts, salt, hash)
+	return d.Algorithm + " " + strings.Join(parts, ", ")
+}
+
+// Fingerprint returns a short fingerprint of b, see Description. It returns an empty string if b is empty.
+func Fingerprint(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
+
+// FormatBytes formats a number of bytes in binary units, for example "64 MiB" or "1536 KiB".
+// The largest unit that represents n exactly is used.
+func FormatBytes(n uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for i < len(units)-1 && n >= 1024 && n%1024 == 0 {
+		n /= 1024
+		i++
+	}
+	return strconv.FormatUint(n, 10) + " " + units[i]
+}
+
+func newDescription(algorithm string, salt, hash []byte, params ...DescriptionParameter) *Description {
+	return &Description{
+		Algorithm:       algorithm,
+		Parameters:      params,
+		SaltLength:      len(salt),
+		SaltFingerprint: Fingerprint(salt),
+		HashLength:      len(hash),
+		HashFingerprint: Fingerprint(hash),
+	}
+}
+
+// Describe returns a summary of the instance, parameter values (including Extra) are included as they are.
+// If Salt or Hash are not decoded the length of SaltString and HashString is used.
+func (instance PHCInstance) Describe() *Description {
+	params := make([]DescriptionParameter, 0, len(instance.Parameters))
+	for _, param := range instance.Parameters {
+		if param.IsSet {
+			params = append(params, DescriptionParameter{Name: param.Name, Value: param.Value})
+		}
+	}
+	for _, param := range instance.Extra {
+		params = append(params, DescriptionParameter{Name: param.Name, Value: param.Value})
+	}
+	salt, hash := instance.Salt, instance.Hash
+	if salt == nil && instance.SaltString != "" {
+		salt = []byte(instance.SaltString)
+	}
+	if hash == nil && instance.HashString != "" {
+		hash = []byte(instance.HashString)
+	}
+	return newDescription(instance.Function, salt, hash, params...)
+}
+
+func (instance PHCInstance) String() string {
+	return instance.Describe().String()
+}
+
+// Format implements fmt.Formatter, the salt and hash are never printed. See Description.Format.
+func (instance PHCInstance) Format(f fmt.State, verb rune) {
+	instance.Describe().Format(f, verb)
+}
+
+// Describe returns a summary of the hash with the memory in human units.
+func (phc *Argon2PHC) Describe() *Description {
+	params := []DescriptionParameter{
+		{Name: "v", Value: strconv.FormatUint(uint64(phc.Version), 10)},
+		{Name: "m", Value: FormatBytes(uint64(phc.M) * 1024)},
+		{Name: "t", Value: strconv.FormatUint(uint64(phc.T), 10)},
+		{Name: "p", Value: strconv.FormatUint(uint64(phc.P), 10)},
+	}
+	if len(phc.KeyID) > 0 {
+		params = append(params, DescriptionParameter{Name: "keyid", Value: hex.EncodeToString(phc.KeyID)})
+	}
+	if len(phc.Data) > 0 {
+		params = append(params, DescriptionParameter{Name: "data", Value: fmt.Sprintf("%d bytes", len(phc.Data))})
+	}
+	if len(phc.PepperID) > 0 {
+		params = append(params, DescriptionParameter{Name: "pid", Value: hex.EncodeToString(phc.PepperID)})
+	}
+	if phc.Normalization != NormalizationNone {
+		params = append(params, DescriptionParameter{Name: "norm", Value: phc.Normalization})
+	}
+	for _, param := range phc.Extra {
+		params = append(params, DescriptionParameter{Name: param.Name, Value: param.Value})
+	}
+	return newDescription(phc.Variant, phc.Salt, phc.Hash, params...)
+}
+
+func (phc *Argon2PHC) String() string {
+	return phc.Describe().String()
+}
+
+// Format implements fmt.Formatter, the salt and hash are never printed. See Description.Format.
+func (phc *Argon2PHC) Format(f fmt.State, verb rune) {
+	phc.Describe().Format(f, verb)
+}
+
+// Describe returns a summary of the hash, the cost is given as power of two. mem is the size of the scrypt
+// vector (128 * r * N) in human units.
+func (phc *ScryptPHC) Describe() *Description {
+	cost := strconv.Itoa(phc.Cost)
+	if phc.Cost > 0 && phc.Cost&(phc.Cost-1) == 0 {
+		cost = fmt.Sprintf("2^%d", bits.TrailingZeros(uint(phc.Cost)))
+	}
+	params := []DescriptionParameter{
+		{Name: "N", Value: cost},
+		{Name: "r", Value: strconv.Itoa(phc.BlockSize)},
+		{Name: "p", Value: strconv.Itoa(phc.Parallelism)},
+		{Name: "mem", Value: FormatBytes(scryptMemory(phc.Cost, phc.BlockSize, 0))},
+	}
+	if len(phc.PepperID) > 0 {
+		params = append(params, DescriptionParameter{Name: "pid", Value: hex.EncodeToString(phc.PepperID)})
+	}
+	if phc.Normalization != NormalizationNone {
+		params = append(params, DescriptionParameter{Name: "norm", Value: phc.Normalization})
+	}
+	for _, param := range phc.Extra {
+		params = append(params, DescriptionParameter{Name: param.Name, Value: param.Value})
+	}
+	return newDescription("scrypt", phc.Salt, phc.Hash, params...)
+}
+
+func (phc *ScryptPHC) String() string {
+	return phc.Describe().String()
+}
+
+// Format implements fmt.Formatter, the salt and hash are never printed. See Description.Format.
+func (phc *ScryptPHC) Format(f fmt.State, verb rune) {
+	phc.Describe().Format(f, verb)
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package gophc
+
+import "log/slog"
+
+// LogValue implements slog.LogValuer, the description is logged as a group.
+func (d *Description) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(d.Parameters)+5)
+	attrs = append(attrs, slog.String("algorithm", d.Algorithm))
+	for _, param := range d.Parameters {
+		attrs = append(attrs, slog.String(param.Name, param.Value))
+	}
+	attrs = append(attrs,
+		slog.Int("salt_length", d.SaltLength),
+		slog.String("salt_fingerprint", d.SaltFingerprint),
+		slog.Int("hash_length", d.HashLength),
+		slog.String("hash_fingerprint", d.HashFingerprint),
+	)
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue implements slog.LogValuer, see Describe.
+func (instance PHCInstance) LogValue() slog.Value {
+	return instance.Describe().LogValue()
+}
+
+// LogValue implements slog.LogValuer, see Describe.
+func (phc *Argon2PHC) LogValue() slog.Value {
+	return phc.Describe().LogValue()
+}
+
+// LogValue implements slog.LogValuer, see Describe.
+func (phc *ScryptPHC) LogValue() slog.Value {
+	return phc.Describe().LogValue()
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+)
+
+// DigestAlgorithms are the algorithms supported by DigestHash.
+var DigestAlgorithms = []string{
+	"md5",
+	"sha1",
+	"sha256",
+	"sha384",
+	"sha512",
+}
+
+func digestHashFunc(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case "md5":
+		return md5.New
+	case "sha1":
+		return sha1.New
+	case "sha256":
+		return sha256.New
+	case "sha384":
+		return sha512.New384
+	case "sha512":
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+// the RFC 2307 scheme names, the salted variant is prefixed with "S"
+var digestLDAPSchemes = map[string]string{
+	"md5":    "MD5",
+	"sha1":   "SHA",
+	"sha256": "SHA256",
+	"sha384": "SHA384",
+	"sha512": "SHA512",
+}
+
+// DigestHash is a (salted) digest of a password: Hash = H(password || Salt).
+//
+// These are the classic LDAP schemes like {SSHA} or {SMD5}, without a salt it is a plain digest of the password.
+// They are not suitable to store passwords and are only supported to verify and migrate existing hashes.
+type DigestHash struct {
+	Algorithm string
+	Salt      []byte
+	Hash      []byte
+}
+
+func (h *DigestHash) ValidateParameters() error {
+	newHash := digestHashFunc(h.Algorithm)
+	if newHash == nil {
+		return NewMismatchedFunctionNameError(h.Algorithm, DigestAlgorithms...)
+	}
+	if len(h.Hash) != newHash().Size() {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("hash must have length %d, got %d", newHash().Size(), len(h.Hash)),
+			"hash", nil)
+	}
+	return nil
+}
+
+func (h *DigestHash) digest(password []byte) []byte {
+	digest := digestHashFunc(h.Algorithm)()
+	digest.Write(password)
+	digest.Write(h.Salt)
+	return digest.Sum(nil)
+}
+
+// LDAPScheme returns the RFC 2307 scheme name, for example "SSHA" or "MD5".
+func (h *DigestHash) LDAPScheme() string {
+	scheme := digestLDAPSchemes[h.Algorithm]
+	if len(h.Salt) > 0 {
+		return "S" + scheme
+	}
+	return scheme
+}
+
+// Encode returns the RFC 2307 representation, for example "{SSHA}<base64 of hash and salt>".
+func (h *DigestHash) Encode() (string, error) {
+	if err := h.ValidateParameters(); err != nil {
+		return "", err
+	}
+	return "{" + h.LDAPScheme() + "}" + h.encodePayload(), nil
+}
+
+func (h *DigestHash) encodePayload() string {
+	payload := make([]byte, 0, len(h.Hash)+len(h.Salt))
+	payload = append(payload, h.Hash...)
+	payload = append(payload, h.Salt...)
+	return base64.StdEncoding.EncodeToString(payload)
+}
+
+// Verify checks if the password matches the hash.
+func (h *DigestHash) Verify(password []byte) (bool, error) {
+	return h.verifyLimits(context.Background(), password, DefaultLimits)
+}
+
+func (h *DigestHash) verifyLimits(_ context.Context, password []byte, limits *Limits) (bool, error) {
+	if err := h.ValidateParameters(); err != nil {
+		return false, err
+	}
+	if limitErr := limits.CheckDigest(h); limitErr != nil {
+		return false, limitErr
+	}
+	return equalAndWipe(h.digest(password), h.Hash), nil
+}
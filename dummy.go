@@ -0,0 +1,99 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"sync"
+)
+
+// DummyVerifier verifies passwords against a fake hash, it is used if a user doesn't exist.
+// This way a login takes the same time (and memory) whether the user exists or not, which prevents user
+// enumeration through timing.
+//
+// The fake hash is computed once with Template (the currently preferred parameters) and a random password,
+// verification decodes the cached string with the registry just as a real verification would.
+// NewDummyVerifier computes the fake hash immediately, otherwise the first login of an unknown user would take
+// longer than all others. A DummyVerifier is safe for concurrent use.
+type DummyVerifier struct {
+	Template PasswordHasher
+	// Registry is used to decode the fake hash, if nil DefaultRegistry is used
+	Registry *Registry
+	mutex    sync.Mutex
+	encoded  string
+}
+
+// NewDummyVerifier returns a verifier with the fake hash already computed, an error is returned if the fake hash
+// can't be computed.
+func NewDummyVerifier(template PasswordHasher, registry *Registry) (*DummyVerifier, error) {
+	res := &DummyVerifier{
+		Template: template,
+		Registry: registry,
+	}
+	if _, err := res.Hash(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (verifier *DummyVerifier) compute() (string, error) {
+	password, err := DefaultSaltGenerator.GenerateN(32)
+	if err != nil {
+		return "", err
+	}
+	h, hashErr := verifier.Template.NewHash(password)
+	if hashErr != nil {
+		return "", hashErr
+	}
+	return h.Encode()
+}
+
+// Hash returns the fake hash, it is computed on the first call if the verifier is not created with
+// NewDummyVerifier.
+// Only a successfully computed hash is cached, after an error the next call tries again.
+func (verifier *DummyVerifier) Hash() (string, error) {
+	verifier.mutex.Lock()
+	defer verifier.mutex.Unlock()
+	if verifier.encoded != "" {
+		return verifier.encoded, nil
+	}
+	encoded, err := verifier.compute()
+	if err != nil {
+		return "", err
+	}
+	verifier.encoded = encoded
+	return encoded, nil
+}
+
+// Verify verifies the password against the fake hash and always returns false.
+// An error is returned only if the fake hash can't be computed or verified.
+func (verifier *DummyVerifier) Verify(password []byte) (bool, error) {
+	return verifier.VerifyContext(context.Background(), password)
+}
+
+// VerifyContext is like Verify, but stops early if ctx is done, see VerifyContext.
+func (verifier *DummyVerifier) VerifyContext(ctx context.Context, password []byte) (bool, error) {
+	encoded, err := verifier.Hash()
+	if err != nil {
+		return false, err
+	}
+	registry := verifier.Registry
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	// the result is ignored: even if the random password is guessed there is no user
+	_, verifyErr := registry.VerifyContext(ctx, encoded, password)
+	return false, verifyErr
+}
@@ -0,0 +1,246 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EnvelopeFunctionName is the phc function name of hashes encrypted with AES-GCM.
+const EnvelopeFunctionName = "enc-aesgcm"
+
+// the standard nonce size of AES-GCM
+const envelopeNonceSize = 12
+
+var (
+	ErrEnvelopeDecryption = errors.New("can't decrypt envelope")
+	ErrUnknownEnvelopeKey = errors.New("unknown envelope key id")
+	ErrInvalidEnvelopeKey = errors.New("invalid envelope key")
+)
+
+// EnvelopeSchema describes strings of the form $enc-aesgcm$kid=<id>$<nonce>$<ciphertext>.
+// The id of the data key is base64 encoded, the nonce is stored as salt and the ciphertext (including the GCM tag)
+// as hash.
+var EnvelopeSchema = &PHCSchema{
+	FunctionNames: []string{EnvelopeFunctionName},
+	ParameterDescriptions: []*PHCParameterDescription{
+		{
+			Name:          "kid",
+			Default:       "",
+			Optional:      false,
+			ValidateValue: ValueCharacterValidator,
+		},
+	},
+	Decoder: DefaultBase64,
+	Encoder: DefaultBase64,
+}
+
+// EnvelopePHC is a password hash string (for example an argon2 phc string) encrypted with AES-GCM.
+//
+// The key id is used as additional data, so an envelope can't be moved to another key id.
+type EnvelopePHC struct {
+	KeyID      []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+func DecodeEnvelope(phcString string) (*EnvelopePHC, error) {
+	return decodeEnvelope(phcString, DefaultLimits)
+}
+
+func decodeEnvelope(phcString string, limits *Limits) (*EnvelopePHC, error) {
+	if limitErr := limits.CheckString(phcString); limitErr != nil {
+		return nil, limitErr
+	}
+	instance, err := EnvelopeSchema.Decode(phcString)
+	if err != nil {
+		return nil, err
+	}
+	// just an assertion, should never happen
+	if len(instance.Parameters) != 1 {
+		return nil, fmt.Errorf("internal error: expected exactly 1 parameter, got %d instead", len(instance.Parameters))
+	}
+	kidParam := instance.Parameters[0]
+	kid, kidErr := EnvelopeSchema.decodeBase64(kidParam.Value)
+	if kidErr != nil {
+		return nil, wrapParameterValueErrorToPHCError("can't decode base64", kidParam.Name, kidErr)
+	}
+	res := &EnvelopePHC{
+		KeyID:      kid,
+		Nonce:      instance.Salt,
+		Ciphertext: instance.Hash,
+	}
+	if err := res.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (e *EnvelopePHC) ValidateParameters() error {
+	if len(e.KeyID) == 0 {
+		return wrapParameterValueErrorToPHCError("must not be empty", "kid", nil)
+	}
+	if len(e.Nonce) != envelopeNonceSize {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("nonce must have %d bytes, got %d", envelopeNonceSize, len(e.Nonce)),
+			"salt", nil)
+	}
+	if len(e.Ciphertext) == 0 {
+		return NewPHCError("envelope contains no ciphertext", ErrMissingHash)
+	}
+	return nil
+}
+
+// Encode returns the phc string of the envelope.
+func (e *EnvelopePHC) Encode() (string, error) {
+	if err := e.ValidateParameters(); err != nil {
+		return "", err
+	}
+	instance := &PHCInstance{
+		Function: EnvelopeFunctionName,
+		Parameters: []ParameterValuePair{
+			{Name: "kid", Value: EnvelopeSchema.encodeBase64(e.KeyID), IsSet: true},
+		},
+		Salt: e.Nonce,
+		Hash: e.Ciphertext,
+	}
+	return EnvelopeSchema.Encode(instance)
+}
+
+// newEnvelopeAEAD returns AES-GCM with the key, the key must have 16, 24 or 32 bytes (AES-128, AES-192 or AES-256).
+func newEnvelopeAEAD(id, key []byte) (cipher.AEAD, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("key id %x: key must have 16, 24 or 32 bytes, got %d: %w", id, len(key),
+			ErrInvalidEnvelopeKey)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptEnvelope encrypts the hash string inner with the current key of ring.
+// The nonce is read from crypto/rand, a repeated nonce would reveal the key stream.
+func EncryptEnvelope(ring KeyRing, inner string) (*EnvelopePHC, error) {
+	id, key := ring.Current()
+	aead, err := newEnvelopeAEAD(id, key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, envelopeNonceSize)
+	if _, nonceErr := io.ReadFull(rand.Reader, nonce); nonceErr != nil {
+		return nil, fmt.Errorf("can't generate nonce: %w", nonceErr)
+	}
+	return &EnvelopePHC{
+		KeyID:      id,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, []byte(inner), id),
+	}, nil
+}
+
+// Decrypt returns the inner hash string, the key is looked up in ring.
+func (e *EnvelopePHC) Decrypt(ring KeyRing) (string, error) {
+	key, has := ring.Key(e.KeyID)
+	if !has {
+		return "", fmt.Errorf("key id %x: %w", e.KeyID, ErrUnknownEnvelopeKey)
+	}
+	aead, err := newEnvelopeAEAD(e.KeyID, key)
+	if err != nil {
+		return "", err
+	}
+	plaintext, openErr := aead.Open(nil, e.Nonce, e.Ciphertext, e.KeyID)
+	if openErr != nil {
+		return "", fmt.Errorf("key id %x: %w", e.KeyID, ErrEnvelopeDecryption)
+	}
+	return string(plaintext), nil
+}
+
+// ReencryptEnvelope decrypts the envelope s and encrypts the inner hash with the current key of ring.
+// This doesn't require the password, so all hashes can be moved to a new key at once.
+func ReencryptEnvelope(ring KeyRing, s string) (string, error) {
+	e, err := DecodeEnvelope(s)
+	if err != nil {
+		return "", err
+	}
+	inner, decryptErr := e.Decrypt(ring)
+	if decryptErr != nil {
+		return "", decryptErr
+	}
+	res, encryptErr := EncryptEnvelope(ring, inner)
+	if encryptErr != nil {
+		return "", encryptErr
+	}
+	return res.Encode()
+}
+
+// EnvelopeHash is a decrypted envelope, Hash is the decoded inner hash.
+// Encode returns the encrypted envelope, Verify verifies the password with the inner hash.
+type EnvelopeHash struct {
+	Envelope *EnvelopePHC
+	Hash     PasswordHash
+}
+
+func (h *EnvelopeHash) Encode() (string, error) {
+	return h.Envelope.Encode()
+}
+
+func (h *EnvelopeHash) Verify(password []byte) (bool, error) {
+	return h.Hash.Verify(password)
+}
+
+// VerifyContext is like Verify, but stops early if ctx is done, see VerifyContext.
+func (h *EnvelopeHash) VerifyContext(ctx context.Context, password []byte) (bool, error) {
+	return VerifyContext(ctx, h.Hash, password)
+}
+
+func (h *EnvelopeHash) verifyLimits(ctx context.Context, password []byte, limits *Limits) (bool, error) {
+	return verifyWithLimits(ctx, h.Hash, password, limits)
+}
+
+// NewEnvelopeDecoder returns a decoder for envelopes, it returns an *EnvelopeHash. The key is looked up in ring and
+// the inner hash is decoded with registry (DefaultRegistry if nil).
+//
+// The decoder can be registered for EnvelopeFunctionName, afterwards the registry verifies envelopes:
+//
+//	registry.Register(NewEnvelopeDecoder(ring, nil), EnvelopeFunctionName)
+func NewEnvelopeDecoder(ring KeyRing, registry *Registry) DecoderFunc {
+	return func(s string) (PasswordHash, error) {
+		innerRegistry := registry
+		if innerRegistry == nil {
+			innerRegistry = DefaultRegistry
+		}
+		e, err := decodeEnvelope(s, innerRegistry.limits())
+		if err != nil {
+			return nil, err
+		}
+		inner, decryptErr := e.Decrypt(ring)
+		if decryptErr != nil {
+			return nil, decryptErr
+		}
+		h, decodeErr := innerRegistry.Decode(inner)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		return &EnvelopeHash{Envelope: e, Hash: h}, nil
+	}
+}
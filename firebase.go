@@ -0,0 +1,270 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+)
+
+// FirebaseScryptFunctionName is the phc function name used for Firebase's modified scrypt.
+const FirebaseScryptFunctionName = "firebase-scrypt"
+
+// the salt separator used by all Firebase projects at the moment (0x07, "Bw==" in standard base64).
+var defaultFirebaseSaltSeparator = []byte{0x07}
+
+// firebaseKeyLength is the length of the scrypt key, it is used as AES-256 key.
+const firebaseKeyLength = 32
+
+// FirebaseScryptPHC describes a password hashed with the modified scrypt from Firebase Auth.
+//
+// The password is hashed with scrypt (N = 2^MemCost, r = Rounds, p = 1) using the salt followed by the salt
+// separator, the result is used as key to encrypt the signer key with AES-256 in CTR mode (zero IV).
+// The encrypted signer key is the hash.
+//
+// The phc format is
+// $firebase-scrypt$rounds=<int>,mem-cost=<int>,signer-key=<b64>[,salt-sep=<b64>]$<salt>$<hash>.
+type FirebaseScryptPHC struct {
+	Rounds        int
+	MemCost       int
+	SignerKey     []byte
+	SaltSeparator []byte
+	Salt          []byte
+	SaltString    string
+	Hash          []byte
+	HashString    string
+}
+
+// NewFirebaseScryptPHC creates an instance from the values found in a Firebase user export and the
+// project hash configuration.
+//
+// All byte values (hash, salt, signer key and salt separator) are expected in standard base64 encoding (with
+// padding) as used by Firebase. hash may be empty, in this case only the parameters and the salt are set.
+func NewFirebaseScryptPHC(hash, salt, signerKey, saltSeparator string, rounds, memCost int) (*FirebaseScryptPHC, error) {
+	decode := func(name, value string) ([]byte, error) {
+		res, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, wrapParameterValueErrorToPHCError("can't decode firebase base64 value", name, newBase64DecodeErrorWrapper(err))
+		}
+		return res, nil
+	}
+	hashBytes, hashErr := decode("hash", hash)
+	if hashErr != nil {
+		return nil, hashErr
+	}
+	saltBytes, saltErr := decode("salt", salt)
+	if saltErr != nil {
+		return nil, saltErr
+	}
+	signerKeyBytes, signerKeyErr := decode("signer-key", signerKey)
+	if signerKeyErr != nil {
+		return nil, signerKeyErr
+	}
+	saltSeparatorBytes, saltSeparatorErr := decode("salt-sep", saltSeparator)
+	if saltSeparatorErr != nil {
+		return nil, saltSeparatorErr
+	}
+	res := &FirebaseScryptPHC{
+		Rounds:        rounds,
+		MemCost:       memCost,
+		SignerKey:     signerKeyBytes,
+		SaltSeparator: saltSeparatorBytes,
+		Salt:          saltBytes,
+		SaltString:    string(Base64Encode(saltBytes)),
+		Hash:          hashBytes,
+		HashString:    string(Base64Encode(hashBytes)),
+	}
+	if err := res.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (phc *FirebaseScryptPHC) ValidateParameters() error {
+	if phc.Rounds < 1 || phc.Rounds > 8 {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("must be between 1 <= rounds <= 8, got %d", phc.Rounds), "rounds", nil)
+	}
+	if phc.MemCost < 1 || phc.MemCost > 14 {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("must be between 1 <= mem-cost <= 14, got %d", phc.MemCost), "mem-cost", nil)
+	}
+	if len(phc.SignerKey) == 0 {
+		return wrapParameterValueErrorToPHCError("signer key must not be empty", "signer-key", nil)
+	}
+	return nil
+}
+
+var FirebaseScryptSchema = &PHCSchema{
+	FunctionNames: []string{FirebaseScryptFunctionName},
+	ParameterDescriptions: []*PHCParameterDescription{
+		{
+			Name:          "rounds",
+			Default:       "",
+			Optional:      false,
+			ValidateValue: NoValueValidator,
+		},
+		{
+			Name:          "mem-cost",
+			Default:       "",
+			Optional:      false,
+			ValidateValue: NoValueValidator,
+		},
+		{
+			Name:          "signer-key",
+			Default:       "",
+			Optional:      false,
+			ValidateValue: ValueCharacterValidator,
+		},
+		{
+			Name:          "salt-sep",
+			Default:       string(Base64Encode(defaultFirebaseSaltSeparator)),
+			Optional:      true,
+			ValidateValue: ValueCharacterValidator,
+		},
+	},
+	Decoder: DefaultBase64,
+	Encoder: DefaultBase64,
+}
+
+func firebaseScryptFromStringParams(roundsParam, memCostParam, signerKeyParam, saltSepParam ParameterValuePair, salt, hash []byte, saltString, hashString string) (*FirebaseScryptPHC, error) {
+	rounds, roundsErr := decodeNoneZeroUnsignedString(roundsParam.Value, false, 32)
+	if roundsErr != nil {
+		return nil, wrapParameterValueErrorToPHCError("can't parse as integer", roundsParam.Name, roundsErr)
+	}
+	memCost, memCostErr := decodeNoneZeroUnsignedString(memCostParam.Value, false, 32)
+	if memCostErr != nil {
+		return nil, wrapParameterValueErrorToPHCError("can't parse as integer", memCostParam.Name, memCostErr)
+	}
+	signerKey, signerKeyErr := FirebaseScryptSchema.decodeBase64(signerKeyParam.Value)
+	if signerKeyErr != nil {
+		return nil, wrapParameterValueErrorToPHCError("can't decode base64", signerKeyParam.Name, signerKeyErr)
+	}
+	saltSep, saltSepErr := FirebaseScryptSchema.decodeBase64(saltSepParam.Value)
+	if saltSepErr != nil {
+		return nil, wrapParameterValueErrorToPHCError("can't decode base64", saltSepParam.Name, saltSepErr)
+	}
+	res := &FirebaseScryptPHC{
+		Rounds:        int(rounds),
+		MemCost:       int(memCost),
+		SignerKey:     signerKey,
+		SaltSeparator: saltSep,
+		Salt:          salt,
+		SaltString:    saltString,
+		Hash:          hash,
+		HashString:    hashString,
+	}
+	return res, nil
+}
+
+func DecodeFirebaseScrypt(phcString string) (*FirebaseScryptPHC, error) {
+	return decodeFirebaseScrypt(phcString, DefaultLimits)
+}
+
+func decodeFirebaseScrypt(phcString string, limits *Limits) (*FirebaseScryptPHC, error) {
+	if limitErr := limits.CheckString(phcString); limitErr != nil {
+		return nil, limitErr
+	}
+	instance, err := FirebaseScryptSchema.Decode(phcString)
+	if err != nil {
+		return nil, err
+	}
+	// just an assertion, should never happen
+	if len(instance.Parameters) != 4 {
+		return nil, fmt.Errorf("internal error: expected exactly 4 parameters, got %d instead", len(instance.Parameters))
+	}
+	res, paramsErr := firebaseScryptFromStringParams(instance.Parameters[0], instance.Parameters[1], instance.Parameters[2],
+		instance.Parameters[3], instance.Salt, instance.Hash, instance.SaltString, instance.HashString)
+	if paramsErr != nil {
+		return nil, paramsErr
+	}
+	if limitErr := limits.CheckFirebaseScrypt(res); limitErr != nil {
+		return nil, limitErr
+	}
+	return res, nil
+}
+
+// Encode returns the phc string of the instance.
+func (phc *FirebaseScryptPHC) Encode() (string, error) {
+	saltSep := ParameterValuePair{Name: "salt-sep"}
+	// only write the salt separator if it is not the default one
+	if string(phc.SaltSeparator) != string(defaultFirebaseSaltSeparator) {
+		saltSep.Value = FirebaseScryptSchema.encodeBase64(phc.SaltSeparator)
+		saltSep.IsSet = true
+	}
+	instance := &PHCInstance{
+		Function: FirebaseScryptFunctionName,
+		Parameters: []ParameterValuePair{
+			{Name: "rounds", Value: strconv.Itoa(phc.Rounds), IsSet: true},
+			{Name: "mem-cost", Value: strconv.Itoa(phc.MemCost), IsSet: true},
+			{Name: "signer-key", Value: FirebaseScryptSchema.encodeBase64(phc.SignerKey), IsSet: true},
+			saltSep,
+		},
+		Salt: phc.Salt,
+		Hash: phc.Hash,
+	}
+	return FirebaseScryptSchema.Encode(instance)
+}
+
+func (phc *FirebaseScryptPHC) computeHash(ctx context.Context, password []byte) ([]byte, error) {
+	// salt followed by the separator, don't modify the salt slice
+	saltWithSep := make([]byte, 0, len(phc.Salt)+len(phc.SaltSeparator))
+	saltWithSep = append(saltWithSep, phc.Salt...)
+	saltWithSep = append(saltWithSep, phc.SaltSeparator...)
+	key, scryptErr := ScryptKeyContext(ctx, password, saltWithSep, 1<<uint(phc.MemCost), phc.Rounds, 1, firebaseKeyLength)
+	if scryptErr != nil {
+		return nil, scryptErr
+	}
+	defer wipeBytes(key)
+	block, aesErr := aes.NewCipher(key)
+	if aesErr != nil {
+		return nil, aesErr
+	}
+	res := make([]byte, len(phc.SignerKey))
+	iv := make([]byte, aes.BlockSize)
+	cipher.NewCTR(block, iv).XORKeyStream(res, phc.SignerKey)
+	return res, nil
+}
+
+// Verify checks if the password matches the hash.
+//
+// An error is returned if the parameters are invalid, the DefaultLimits are exceeded or no hash is given.
+func (phc *FirebaseScryptPHC) Verify(password []byte) (bool, error) {
+	return phc.VerifyContext(context.Background(), password)
+}
+
+// VerifyContext is like Verify, but stops early if ctx is done, see ScryptKeyContext.
+func (phc *FirebaseScryptPHC) VerifyContext(ctx context.Context, password []byte) (bool, error) {
+	return phc.verifyLimits(ctx, password, DefaultLimits)
+}
+
+func (phc *FirebaseScryptPHC) verifyLimits(ctx context.Context, password []byte, limits *Limits) (bool, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return false, err
+	}
+	if limitErr := limits.CheckFirebaseScrypt(phc); limitErr != nil {
+		return false, limitErr
+	}
+	if len(phc.Hash) == 0 {
+		return false, NewPHCError("can't verify password", ErrMissingHash)
+	}
+	computed, err := phc.computeHash(ctx, password)
+	if err != nil {
+		return false, err
+	}
+	return equalAndWipe(computed, phc.Hash), nil
+}
@@ -25,6 +25,7 @@ var (
 	ErrParameterValueValidation    = errors.New("validation of parameter failed")
 	ErrMismatchedFunctionName      = errors.New("invalid function name")
 	ErrUnmatchedParameterName      = errors.New("unmatched parameter parsed")
+	ErrDuplicateParameter          = errors.New("duplicate parameter")
 )
 
 func NewMismatchedFunctionNameError(gotName string, expectedNames ...string) error {
@@ -109,10 +110,102 @@ func (description *PHCParameterDescription) GetValueValidatorFunc() ValueValidat
 	return description.ValidateValue
 }
 
+// UnknownParameterPolicy describes how a PHCSchema handles parameters that are not described in the schema.
+type UnknownParameterPolicy int
+
+const (
+	// RejectUnknownParameters returns an ErrUnmatchedParameterName error, this is the default
+	RejectUnknownParameters UnknownParameterPolicy = iota
+	// IgnoreUnknownParameters drops unknown parameters
+	IgnoreUnknownParameters
+	// PreserveUnknownParameters stores unknown parameters in PHCInstance.Extra
+	PreserveUnknownParameters
+)
+
 type PHCSchema struct {
 	FunctionNames         []string
 	ParameterDescriptions []*PHCParameterDescription
 	Decoder               Base64Decoder
+	Encoder               Base64Encoder
+	// UnorderedParameters matches parsed parameters by name, regardless of their order. By default the parameters
+	// must appear in the order of ParameterDescriptions as required by the phc format.
+	// Duplicate parameters are an error, unknown parameters are handled according to UnknownParameters in both
+	// modes. Encode always writes the order of the schema.
+	UnorderedParameters bool
+	// UnknownParameters describes how parameters that are not described in the schema are handled, this allows
+	// readers to process strings from newer writers that add parameters
+	UnknownParameters UnknownParameterPolicy
+}
+
+// ParameterOptions change how the parameters of a phc string are matched, see the fields of PHCSchema with the same
+// names. They allow decoding strings of other writers with the schemas of this package.
+type ParameterOptions struct {
+	UnorderedParameters bool
+	UnknownParameters   UnknownParameterPolicy
+}
+
+// withOptions returns a copy of the schema with the options applied.
+func (schema *PHCSchema) withOptions(options ParameterOptions) *PHCSchema {
+	res := *schema
+	res.UnorderedParameters = options.UnorderedParameters
+	res.UnknownParameters = options.UnknownParameters
+	return &res
+}
+
+// describes returns true if the schema contains a description for the parameter.
+func (schema *PHCSchema) describes(name string) bool {
+	for _, description := range schema.ParameterDescriptions {
+		if description.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExtraParameter checks the name and value of a parameter that is not described in the schema.
+func validateExtraParameter(param ParameterValuePair) error {
+	if param.Name == "" {
+		return NewPHCError("empty parameter name", ErrInvalidParameterName)
+	}
+	if onlyValidRunes, invalidRune := validateRuneFunc(isValidParameterNameRune, param.Name); !onlyValidRunes {
+		return NewPHCError(fmt.Sprintf("parameter name \"%s\" contains invalid character \"%s\"", param.Name, string(invalidRune)),
+			ErrInvalidParameterName)
+	}
+	if validationErr := ValueCharacterValidator(param.Value); validationErr != nil {
+		return wrapParameterValueErrorToPHCError("value validation failed", param.Name, validationErr)
+	}
+	return nil
+}
+
+// unknownParameter handles a parameter that is not described in the schema according to UnknownParameters,
+// preserved parameters are appended to extra.
+func (schema *PHCSchema) unknownParameter(param ParameterValuePair, extra []ParameterValuePair) ([]ParameterValuePair, error) {
+	switch schema.UnknownParameters {
+	case IgnoreUnknownParameters:
+		return extra, nil
+	case PreserveUnknownParameters:
+		if err := validateExtraParameter(param); err != nil {
+			return nil, err
+		}
+		for _, preserved := range extra {
+			if preserved.Name == param.Name {
+				return nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", param.Name), ErrDuplicateParameter)
+			}
+		}
+		return append(extra, param), nil
+	default:
+		return nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", param.Name), ErrUnmatchedParameterName)
+	}
+}
+
+// isMatched returns true if a parameter with the given name is already set in matched.
+func isMatched(matched []ParameterValuePair, name string) bool {
+	for _, param := range matched {
+		if param.IsSet && param.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 // parseParameter parses a parameter from a string of the form "name=value".
@@ -145,14 +238,40 @@ func parseParameters(s string) ([]ParameterValuePair, error) {
 	return res, nil
 }
 
+// versionParameterName is the name of the optional version segment "$v=<version>".
+const versionParameterName = "v"
+
+// isVersionSegment returns true if s is of the form "v=<value>".
+func isVersionSegment(s string) bool {
+	return strings.HasPrefix(s, versionParameterName+"=") && !strings.ContainsRune(s, ',')
+}
+
+// matchParameters returns the parameters in the order of the descriptions and the parameters not described in
+// the schema (see UnknownParameters).
 // TODO: check if PHCError is used correctly everywhere
-func (schema *PHCSchema) matchParameters(parsedParameters []ParameterValuePair) ([]ParameterValuePair, error) {
+func (schema *PHCSchema) matchParameters(parsedParameters []ParameterValuePair) ([]ParameterValuePair, []ParameterValuePair, error) {
+	if schema.UnorderedParameters {
+		return schema.matchUnorderedParameters(parsedParameters)
+	}
+	var extra []ParameterValuePair
 	descriptionIndex, parsedIndex := 0, 0
 	n, m := len(schema.ParameterDescriptions), len(parsedParameters)
 	res := make([]ParameterValuePair, n)
 	for descriptionIndex < n && parsedIndex < m {
 		nextDescription := schema.ParameterDescriptions[descriptionIndex]
 		nextParsed := parsedParameters[parsedIndex]
+		// parameters not described in the schema can appear anywhere
+		if !schema.describes(nextParsed.Name) {
+			var unknownErr error
+			if extra, unknownErr = schema.unknownParameter(nextParsed, extra); unknownErr != nil {
+				return nil, nil, unknownErr
+			}
+			parsedIndex++
+			continue
+		}
+		if isMatched(res[:descriptionIndex], nextParsed.Name) {
+			return nil, nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", nextParsed.Name), ErrDuplicateParameter)
+		}
 		// now we expect the next description
 		// if it is not this parameter name, we have to check if the next description
 		// is optional, if yes we only continue in the descriptions, but not the parsed
@@ -160,7 +279,7 @@ func (schema *PHCSchema) matchParameters(parsedParameters []ParameterValuePair)
 			// in case of a match: validate the value
 			validatorFunc := nextDescription.GetValueValidatorFunc()
 			if validationErr := validatorFunc(nextParsed.Value); validationErr != nil {
-				return nil, wrapParameterValueErrorToPHCError("value validation failed", nextDescription.Name, validationErr)
+				return nil, nil, wrapParameterValueErrorToPHCError("value validation failed", nextDescription.Name, validationErr)
 			}
 			// add to result
 			// parsed parameter always have IsSet = true
@@ -171,7 +290,7 @@ func (schema *PHCSchema) matchParameters(parsedParameters []ParameterValuePair)
 		} else {
 			// now next description must be optional
 			if !nextDescription.Optional {
-				return nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", nextDescription.Name), ErrNonOptionalParameterMissing)
+				return nil, nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", nextDescription.Name), ErrNonOptionalParameterMissing)
 			}
 			// add it with the default
 			entry := ParameterValuePair{
@@ -184,16 +303,25 @@ func (schema *PHCSchema) matchParameters(parsedParameters []ParameterValuePair)
 		}
 	}
 	// now there might still be additional parsed / descriptions (but not both)
-	// if parsed parameters are left: return an error (too many parameters)
-	if parsedIndex < m {
+	// if parsed parameters are left they must be unknown parameters, described ones are out of order
+	for ; parsedIndex < m; parsedIndex++ {
 		nextParsed := parsedParameters[parsedIndex]
-		return nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", nextParsed.Name), ErrUnmatchedParameterName)
+		if schema.describes(nextParsed.Name) {
+			if isMatched(res, nextParsed.Name) {
+				return nil, nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", nextParsed.Name), ErrDuplicateParameter)
+			}
+			return nil, nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", nextParsed.Name), ErrUnmatchedParameterName)
+		}
+		var unknownErr error
+		if extra, unknownErr = schema.unknownParameter(nextParsed, extra); unknownErr != nil {
+			return nil, nil, unknownErr
+		}
 	}
 	for ; descriptionIndex < n; descriptionIndex++ {
 		nextDescription := schema.ParameterDescriptions[descriptionIndex]
 		// now next description must be optional
 		if !nextDescription.Optional {
-			return nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", nextDescription.Name), ErrNonOptionalParameterMissing)
+			return nil, nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", nextDescription.Name), ErrNonOptionalParameterMissing)
 		}
 		// add it with the default
 		entry := ParameterValuePair{
@@ -203,7 +331,50 @@ func (schema *PHCSchema) matchParameters(parsedParameters []ParameterValuePair)
 		}
 		res[descriptionIndex] = entry
 	}
-	return res, nil
+	return res, extra, nil
+}
+
+// matchUnorderedParameters matches the parsed parameters by name, the result is in the order of the descriptions.
+func (schema *PHCSchema) matchUnorderedParameters(parsedParameters []ParameterValuePair) ([]ParameterValuePair, []ParameterValuePair, error) {
+	parsedByName := make(map[string]ParameterValuePair, len(parsedParameters))
+	for _, parsed := range parsedParameters {
+		if _, has := parsedByName[parsed.Name]; has {
+			return nil, nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", parsed.Name), ErrDuplicateParameter)
+		}
+		parsedByName[parsed.Name] = parsed
+	}
+	res := make([]ParameterValuePair, len(schema.ParameterDescriptions))
+	for i, description := range schema.ParameterDescriptions {
+		parsed, has := parsedByName[description.Name]
+		if !has {
+			if !description.Optional {
+				return nil, nil, NewPHCError(fmt.Sprintf("parameter \"%s\"", description.Name), ErrNonOptionalParameterMissing)
+			}
+			res[i] = ParameterValuePair{
+				Name:  description.Name,
+				Value: description.Default,
+				IsSet: false,
+			}
+			continue
+		}
+		validatorFunc := description.GetValueValidatorFunc()
+		if validationErr := validatorFunc(parsed.Value); validationErr != nil {
+			return nil, nil, wrapParameterValueErrorToPHCError("value validation failed", description.Name, validationErr)
+		}
+		res[i] = parsed
+		delete(parsedByName, description.Name)
+	}
+	// all remaining parameters are not described in the schema
+	var extra []ParameterValuePair
+	for _, parsed := range parsedParameters {
+		if _, has := parsedByName[parsed.Name]; has {
+			var unknownErr error
+			if extra, unknownErr = schema.unknownParameter(parsed, extra); unknownErr != nil {
+				return nil, nil, unknownErr
+			}
+		}
+	}
+	return res, extra, nil
 }
 
 func (schema *PHCSchema) decodeBase64(s string) ([]byte, error) {
@@ -256,6 +427,12 @@ func (schema *PHCSchema) Decode(s string) (PHCInstance, error) {
 	// we don't have to check for empty string here, we already did that
 	// if string contains '=' it is a parameter string, otherwise it is not and should be parsed
 	// as hash / salt
+	// the phc format allows an optional version segment "$v=<version>" before the parameters, in this case
+	// it is treated as the first parameter
+	if len(split) > 1 && isVersionSegment(split[0]) && strings.ContainsRune(split[1], '=') {
+		split[1] = split[0] + "," + split[1]
+		split = split[1:]
+	}
 	if len(split) > 0 && strings.ContainsRune(split[0], '=') {
 		var parametersParseError error
 		parsedParameters, parametersParseError = parseParameters(split[0])
@@ -265,11 +442,12 @@ func (schema *PHCSchema) Decode(s string) (PHCInstance, error) {
 		split = split[1:]
 	}
 	// now match the parsed parameters against the description
-	finalParams, matchErr := schema.matchParameters(parsedParameters)
+	finalParams, extra, matchErr := schema.matchParameters(parsedParameters)
 	if matchErr != nil {
 		return res, matchErr
 	}
 	res.Parameters = finalParams
+	res.Extra = extra
 	// now parse salt / hash (if given)
 	if len(split) == 0 {
 		return res, nil
@@ -305,3 +483,104 @@ func (schema *PHCSchema) Decode(s string) (PHCInstance, error) {
 
 	return res, nil
 }
+
+func (schema *PHCSchema) encodeBase64(b []byte) string {
+	encoder := schema.Encoder
+	if encoder == nil {
+		encoder = DefaultBase64
+	}
+	return string(encoder.Base64Encode(b))
+}
+
+// Encode returns the phc string for the given instance.
+// Parameters are written in the order of the schema, optional parameters that are not set are omitted.
+// The Extra parameters of the instance are written afterwards, their names must not be described in the schema.
+// If the first parameter written is "v" it is written in its own version segment as described in the phc format.
+// The salt and hash are encoded from the byte slices, the string representations are ignored.
+// If there is no salt, the instance is not allowed to contain a hash.
+func (schema *PHCSchema) Encode(instance *PHCInstance) (string, error) {
+	foundFunctionName := false
+	for _, potentialFuncName := range schema.FunctionNames {
+		if potentialFuncName == instance.Function {
+			foundFunctionName = true
+			break
+		}
+	}
+	if !foundFunctionName {
+		return "", NewMismatchedFunctionNameError(instance.Function, schema.FunctionNames...)
+	}
+
+	var buffer strings.Builder
+	buffer.WriteRune('$')
+	buffer.WriteString(instance.Function)
+
+	// collect all parameters that must be written, in the order of the schema
+	values := make(map[string]ParameterValuePair, len(instance.Parameters))
+	for _, param := range instance.Parameters {
+		values[param.Name] = param
+	}
+	first := true
+	for _, description := range schema.ParameterDescriptions {
+		param, has := values[description.Name]
+		if !has || !param.IsSet {
+			if !description.Optional {
+				return "", NewPHCError(fmt.Sprintf("parameter \"%s\"", description.Name), ErrNonOptionalParameterMissing)
+			}
+			continue
+		}
+		validatorFunc := description.GetValueValidatorFunc()
+		if validationErr := validatorFunc(param.Value); validationErr != nil {
+			return "", wrapParameterValueErrorToPHCError("value validation failed", description.Name, validationErr)
+		}
+		// the version is written in its own segment
+		if description.Name == versionParameterName && first {
+			buffer.WriteString("$v=")
+			buffer.WriteString(param.Value)
+			continue
+		}
+		if first {
+			buffer.WriteRune('$')
+			first = false
+		} else {
+			buffer.WriteRune(',')
+		}
+		buffer.WriteString(description.Name)
+		buffer.WriteRune('=')
+		buffer.WriteString(param.Value)
+	}
+	written := make(map[string]struct{}, len(instance.Extra))
+	for _, param := range instance.Extra {
+		if _, has := written[param.Name]; has || schema.describes(param.Name) {
+			return "", NewPHCError(fmt.Sprintf("parameter \"%s\"", param.Name), ErrDuplicateParameter)
+		}
+		written[param.Name] = struct{}{}
+		if err := validateExtraParameter(param); err != nil {
+			return "", err
+		}
+		if first {
+			buffer.WriteRune('$')
+			first = false
+		} else {
+			buffer.WriteRune(',')
+		}
+		buffer.WriteString(param.Name)
+		buffer.WriteRune('=')
+		buffer.WriteString(param.Value)
+	}
+
+	if len(instance.Salt) == 0 {
+		if len(instance.Hash) != 0 {
+			return "", newInvalidPHCStructureError("hash given without a salt")
+		}
+		return buffer.String(), nil
+	}
+	buffer.WriteRune('$')
+	buffer.WriteString(schema.encodeBase64(instance.Salt))
+
+	if len(instance.Hash) == 0 {
+		return buffer.String(), nil
+	}
+	buffer.WriteRune('$')
+	buffer.WriteString(schema.encodeBase64(instance.Hash))
+	return buffer.String(), nil
+}
@@ -1,3 +1,8 @@
 module github.com/FabianWe/gophc
 
 go 1.14
+
+require (
+	golang.org/x/crypto v0.23.0
+	golang.org/x/text v0.22.0
+)
@@ -0,0 +1,110 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidTemplate = errors.New("invalid hasher template")
+)
+
+// Hasher computes new phc strings with a template, the template is usually decoded from a parameters only config
+// string like "$argon2id$v=19$m=65536,t=3,p=4" (see NewHasher).
+type Hasher struct {
+	Template PasswordHasher
+	// limits are the limits of the registry that decoded the template, if nil DefaultLimits is used
+	limits *Limits
+}
+
+// limitedHasher is implemented by hashers that check resource limits before a new hash is computed.
+// NewHash and NewHashContext of these hashers check the DefaultLimits, a Hasher passes the limits of its registry
+// instead.
+type limitedHasher interface {
+	hashLimits(ctx context.Context, password []byte, limits *Limits) (PasswordHash, error)
+}
+
+// NewHasher decodes the config string with DefaultRegistry, see Registry.NewHasher.
+func NewHasher(config string) (*Hasher, error) {
+	return DefaultRegistry.NewHasher(config)
+}
+
+// NewHasher decodes the config string and returns a Hasher that uses it as template.
+//
+// The config string must contain the parameters only (no salt or hash) and must describe a hash that can be used
+// as a template (argon2 and scrypt). The parameters and the limits of the registry are checked here, so an invalid
+// configuration is detected before the first password is hashed. The Hasher enforces the same limits.
+//
+// An argon2 config with a keyid is rejected with ErrMissingSecret, a Hasher doesn't know the secret.
+func (registry *Registry) NewHasher(config string) (*Hasher, error) {
+	h, err := registry.Decode(config)
+	if err != nil {
+		return nil, err
+	}
+	template, ok := h.(PasswordHasher)
+	if !ok {
+		return nil, NewPHCError(fmt.Sprintf("can't use %T as template", h), ErrInvalidTemplate)
+	}
+	var salt, hash []byte
+	switch v := h.(type) {
+	case *Argon2PHC:
+		if len(v.KeyID) > 0 {
+			return nil, fmt.Errorf("key id %x: %w", v.KeyID, ErrMissingSecret)
+		}
+		salt, hash = v.Salt, v.Hash
+	case *ScryptPHC:
+		salt, hash = v.Salt, v.Hash
+	}
+	if len(salt) > 0 || len(hash) > 0 {
+		return nil, NewPHCError("template must not contain a salt or hash", ErrInvalidTemplate)
+	}
+	if validator, ok := h.(interface{ ValidateParameters() error }); ok {
+		if validationErr := validator.ValidateParameters(); validationErr != nil {
+			return nil, validationErr
+		}
+	}
+	limits := registry.limits()
+	if limitErr := limits.Check(h); limitErr != nil {
+		return nil, limitErr
+	}
+	return &Hasher{Template: template, limits: limits}, nil
+}
+
+// Hash computes a new hash of the password with a new random salt and returns its phc string.
+func (hasher *Hasher) Hash(password []byte) (string, error) {
+	return hasher.HashContext(context.Background(), password)
+}
+
+// HashContext is like Hash, but stops early if ctx is done, see NewHashContext.
+func (hasher *Hasher) HashContext(ctx context.Context, password []byte) (string, error) {
+	var h PasswordHash
+	var err error
+	if limited, ok := hasher.Template.(limitedHasher); ok {
+		limits := hasher.limits
+		if limits == nil {
+			limits = DefaultLimits
+		}
+		h, err = limited.hashLimits(ctx, password, limits)
+	} else {
+		h, err = NewHashContext(ctx, hasher.Template, password)
+	}
+	if err != nil {
+		return "", err
+	}
+	return h.Encode()
+}
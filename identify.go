@@ -0,0 +1,334 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Confidence describes how certain an identification is.
+type Confidence int
+
+const (
+	// ConfidenceLow means that the string has the shape of the format, but other formats are likely as well.
+	ConfidenceLow Confidence = iota + 1
+	// ConfidenceMedium means that the string has the structure of the format but could not be decoded.
+	ConfidenceMedium
+	// ConfidenceHigh means that the string was decoded successfully.
+	ConfidenceHigh
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("Confidence(%d)", int(c))
+	}
+}
+
+// Identification is a candidate format for a credential string.
+//
+// Decoder decodes the string (the complete string, including prefixes), it is nil if the format is known but
+// not supported by this package.
+type Identification struct {
+	Format     string
+	Confidence Confidence
+	Decoder    DecoderFunc
+}
+
+// modular crypt format ids that are recognized but not supported
+var knownCryptFormats = map[string]string{
+	"1":    "md5-crypt",
+	"apr1": "apr1-md5-crypt",
+	"md5":  "sun-md5-crypt",
+	"sha1": "sha1-crypt",
+	"y":    "yescrypt",
+	"gy":   "gost-yescrypt",
+	"7":    "scrypt-crypt",
+	"2x":   "bcrypt",
+}
+
+// the names of the formats decoded by the default registry, if the format is not found here the function name
+// is used
+var registryFormats = map[string]string{
+	"2a": "bcrypt",
+	"2b": "bcrypt",
+	"2y": "bcrypt",
+	"5":  "sha256-crypt",
+	"6":  "sha512-crypt",
+}
+
+// Identify returns the candidate formats of s using the DefaultRegistry.
+func Identify(s string) []Identification {
+	return DefaultRegistry.Identify(s)
+}
+
+// Identify returns the candidate formats of s, sorted by confidence (highest first).
+//
+// Strings starting with '$' are first parsed with a PHCParser and the registry, then prefixed formats (LDAP,
+// Spring), Django and passlib strings, ASP.NET Identity hashes and finally bare hex digests are checked.
+// The result is empty if the format can't be identified.
+func (registry *Registry) Identify(s string) []Identification {
+	var res []Identification
+	add := func(format string, confidence Confidence, decoder DecoderFunc) {
+		res = append(res, Identification{Format: format, Confidence: confidence, Decoder: decoder})
+	}
+	switch {
+	case strings.HasPrefix(s, "$"):
+		registry.identifyDollar(s, add)
+	case strings.HasPrefix(s, "{"):
+		identifyPrefixed(s, add)
+	case strings.ContainsRune(s, '$'):
+		registry.identifyDjango(s, add)
+	default:
+		identifyASPNet(s, add)
+		identifyHex(s, add)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Confidence > res[j].Confidence
+	})
+	return res
+}
+
+type identificationAdder func(format string, confidence Confidence, decoder DecoderFunc)
+
+func (registry *Registry) identifyDollar(s string, add identificationAdder) {
+	functionName, _ := FunctionName(s)
+	format := functionName
+	if name, has := registryFormats[functionName]; has {
+		format = name
+	}
+	decoder, registered := registry.Decoder(functionName)
+	if registered {
+		if _, err := decoder(s); err == nil {
+			add(format, ConfidenceHigh, decoder)
+			return
+		}
+		add(format, ConfidenceMedium, decoder)
+	}
+	// passlib writes pbkdf2 hashes as $pbkdf2-sha256$<rounds>$<salt>$<hash>, this is not a valid phc string
+	if variant, isPasslib := passlibPBKDF2Variant(functionName); isPasslib {
+		passlibDecoder := decodePasslibPBKDF2(variant)
+		if _, err := passlibDecoder(s); err == nil {
+			add("passlib-"+variant, ConfidenceHigh, passlibDecoder)
+		} else if !registered {
+			add("passlib-"+variant, ConfidenceMedium, passlibDecoder)
+		}
+		return
+	}
+	if registered {
+		return
+	}
+	if name, has := knownCryptFormats[functionName]; has {
+		add(name, ConfidenceMedium, nil)
+		return
+	}
+	// unknown function: if it is at least a valid phc string it is reported with the function name
+	if _, err := NewPHCParser().Parse(s); err == nil {
+		add(functionName, ConfidenceLow, nil)
+	}
+}
+
+func passlibPBKDF2Variant(functionName string) (string, bool) {
+	switch functionName {
+	case "pbkdf2":
+		return "pbkdf2-sha1", true
+	case "pbkdf2-sha256", "pbkdf2-sha512":
+		return functionName, true
+	default:
+		return "", false
+	}
+}
+
+func decodePasslibPBKDF2(variant string) DecoderFunc {
+	decodePayload := decodeLDAPPBKDF2(variant)
+	return func(s string) (PasswordHash, error) {
+		// remove "$<function>$"
+		split := strings.SplitN(s, "$", 3)
+		if len(split) != 3 || split[0] != "" {
+			return nil, newInvalidPHCStructureError("passlib hash must be of the form $<function>$<rounds>$<salt>$<hash>")
+		}
+		return decodePayload(split[2])
+	}
+}
+
+func identifyPrefixed(s string, add identificationAdder) {
+	prefix, _, err := SplitLDAPScheme(s)
+	if err != nil {
+		return
+	}
+	if _, isLDAP := ldapCodecs[strings.ToUpper(prefix)]; isLDAP {
+		format := "ldap-" + strings.ToLower(prefix)
+		if _, decodeErr := DecodeLDAP(s); decodeErr == nil {
+			add(format, ConfidenceHigh, decodeLDAPHash)
+		} else {
+			add(format, ConfidenceMedium, decodeLDAPHash)
+		}
+	}
+	if _, isSpring := SpringSchemes[prefix]; isSpring || prefix == SpringScryptID {
+		format := "spring-" + prefix
+		if _, decodeErr := DecodeSpring(s); decodeErr == nil {
+			add(format, ConfidenceHigh, decodeSpringHash)
+		} else {
+			add(format, ConfidenceMedium, decodeSpringHash)
+		}
+	}
+}
+
+func decodeLDAPHash(s string) (PasswordHash, error) {
+	res, err := DecodeLDAP(s)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func decodeSpringHash(s string) (PasswordHash, error) {
+	res, err := DecodeSpring(s)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Django stores hashes as <algorithm>$<rest>, the supported algorithms are mapped to decoders
+func (registry *Registry) identifyDjango(s string, add identificationAdder) {
+	index := strings.IndexRune(s, '$')
+	algorithm, rest := s[:index], s[index+1:]
+	var decoder DecoderFunc
+	switch algorithm {
+	case "pbkdf2_sha256":
+		decoder = decodeDjangoPBKDF2("pbkdf2-sha256")
+	case "pbkdf2_sha1":
+		decoder = decodeDjangoPBKDF2("pbkdf2-sha1")
+	case "argon2", "bcrypt":
+		// argon2$argon2id$v=19$... contains the phc string without its leading '$',
+		// bcrypt$$2b$... contains the complete bcrypt hash
+		decoder = func(s string) (PasswordHash, error) {
+			encoded := s[strings.IndexRune(s, '$')+1:]
+			if !strings.HasPrefix(encoded, "$") {
+				encoded = "$" + encoded
+			}
+			return registry.Decode(encoded)
+		}
+	case "bcrypt_sha256", "scrypt", "md5", "sha1", "unsalted_md5", "unsalted_sha1", "crypt":
+		// known, but not supported
+		if rest != "" {
+			add("django-"+algorithm, ConfidenceMedium, nil)
+		}
+		return
+	default:
+		return
+	}
+	format := "django-" + strings.Replace(algorithm, "_", "-", -1)
+	if _, err := decoder(s); err == nil {
+		add(format, ConfidenceHigh, decoder)
+	} else {
+		add(format, ConfidenceMedium, decoder)
+	}
+}
+
+// decodeDjangoPBKDF2 decodes pbkdf2_<digest>$<iterations>$<salt>$<hash>, the salt is used as is and the hash
+// is encoded in standard base64.
+func decodeDjangoPBKDF2(variant string) DecoderFunc {
+	return func(s string) (PasswordHash, error) {
+		split := strings.Split(s, "$")
+		if len(split) != 4 {
+			return nil, newInvalidPHCStructureError("django hash must be of the form <algorithm>$<iterations>$<salt>$<hash>")
+		}
+		iterations, iterationsErr := decodeNoneZeroUnsignedString(split[1], false, 31)
+		if iterationsErr != nil {
+			return nil, wrapParameterValueErrorToPHCError("can't parse as integer", "iterations", iterationsErr)
+		}
+		hash, hashErr := base64.StdEncoding.DecodeString(split[3])
+		if hashErr != nil {
+			return nil, NewPHCError("error decoding hash from base64", newBase64DecodeErrorWrapper(hashErr))
+		}
+		return newLDAPPBKDF2PHC(variant, int(iterations), []byte(split[2]), hash), nil
+	}
+}
+
+func identifyASPNet(s string, add identificationAdder) {
+	blob, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(blob) == 0 {
+		return
+	}
+	var format string
+	switch ASPNetIdentityVersion(blob[0]) {
+	case ASPNetIdentityV2:
+		format = "aspnet-identity-v2"
+	case ASPNetIdentityV3:
+		format = "aspnet-identity-v3"
+	default:
+		return
+	}
+	if _, decodeErr := DecodeASPNetIdentity(s); decodeErr == nil {
+		add(format, ConfidenceHigh, decodeASPNetIdentityHash)
+	}
+}
+
+func decodeASPNetIdentityHash(s string) (PasswordHash, error) {
+	res, err := DecodeASPNetIdentity(s)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func identifyHex(s string, add identificationAdder) {
+	if _, err := hex.DecodeString(s); err != nil {
+		return
+	}
+	switch len(s) {
+	case 32:
+		add("md5", ConfidenceMedium, DecodeHexDigest("md5"))
+		add("ntlm", ConfidenceLow, nil)
+	case 40:
+		add("sha1", ConfidenceMedium, DecodeHexDigest("sha1"))
+	case 64:
+		add("sha256", ConfidenceMedium, DecodeHexDigest("sha256"))
+	case 96:
+		add("sha384", ConfidenceMedium, DecodeHexDigest("sha384"))
+	case 128:
+		add("sha512", ConfidenceMedium, DecodeHexDigest("sha512"))
+	}
+}
+
+// DecodeHexDigest returns a decoder for unsalted hex encoded digests of the given algorithm.
+func DecodeHexDigest(algorithm string) DecoderFunc {
+	return func(s string) (PasswordHash, error) {
+		hash, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, NewPHCError("error decoding hex digest", err)
+		}
+		res := &DigestHash{
+			Algorithm: algorithm,
+			Hash:      hash,
+		}
+		if validationErr := res.ValidateParameters(); validationErr != nil {
+			return nil, validationErr
+		}
+		return res, nil
+	}
+}
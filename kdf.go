@@ -0,0 +1,192 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidKeyLength = errors.New("invalid key length")
+	ErrKDFNotSupported  = errors.New("hash can't be used to derive keys")
+	ErrMissingSecret    = errors.New("no secret key given")
+)
+
+// KeyDeriver is implemented by hashes that can be used as key derivation function, the parameters and salt
+// are taken from the hash (usually decoded from a config string like "$argon2id$v=19$m=65536,t=3,p=4$<salt>").
+type KeyDeriver interface {
+	DeriveKeyContext(ctx context.Context, passphrase []byte, length int) ([]byte, error)
+}
+
+// limitedKeyDeriver is implemented by the KeyDeriver types of this package, a Registry passes its own limits
+// instead of DefaultLimits.
+type limitedKeyDeriver interface {
+	deriveKeyLimits(ctx context.Context, passphrase []byte, length int, limits *Limits) ([]byte, error)
+}
+
+// checkKeyLength checks that length is within min and max and doesn't exceed the MaxHashLength of limits.
+func checkKeyLength(length int, min, max uint64, limits *Limits) error {
+	if length < 0 || uint64(length) < min || uint64(length) > max {
+		return fmt.Errorf("key length must be between %d and %d, got %d: %w", min, max, length, ErrInvalidKeyLength)
+	}
+	if limits != nil && exceeds(uint64(length), uint64(limits.MaxHashLength)) {
+		return fmt.Errorf("key length %d exceeds limit %d: %w", length, limits.MaxHashLength, ErrResourceLimitExceeded)
+	}
+	return nil
+}
+
+// DeriveKey derives a key with the given length from the passphrase, see DeriveKeyContext.
+func (phc *Argon2PHC) DeriveKey(passphrase []byte, length int) ([]byte, error) {
+	return phc.DeriveKeyContext(context.Background(), passphrase, length)
+}
+
+// DeriveKeyWithSecret is like DeriveKey, but derives the key with the secret key K identified by KeyID.
+func (phc *Argon2PHC) DeriveKeyWithSecret(passphrase, secret []byte, length int) ([]byte, error) {
+	return phc.deriveKey(context.Background(), passphrase, secret, length, DefaultLimits)
+}
+
+// DeriveKeyContext derives a key with the given length from the passphrase with the parameters and salt of phc.
+// The hash of phc is ignored, the passphrase is normalized as for passwords.
+//
+// The length is restricted by the MaxHashLength of DefaultLimits. If phc has a KeyID ErrMissingSecret is returned,
+// use DeriveKeyWithSecret instead.
+func (phc *Argon2PHC) DeriveKeyContext(ctx context.Context, passphrase []byte, length int) ([]byte, error) {
+	return phc.deriveKey(ctx, passphrase, nil, length, DefaultLimits)
+}
+
+func (phc *Argon2PHC) deriveKeyLimits(ctx context.Context, passphrase []byte, length int, limits *Limits) ([]byte, error) {
+	return phc.deriveKey(ctx, passphrase, nil, length, limits)
+}
+
+func (phc *Argon2PHC) deriveKey(ctx context.Context, passphrase, secret []byte, length int, limits *Limits) ([]byte, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	if limitErr := limits.CheckArgon2(phc); limitErr != nil {
+		return nil, limitErr
+	}
+	if len(phc.Salt) == 0 {
+		return nil, NewPHCError("can't derive key", ErrMissingSalt)
+	}
+	// without the secret the key would silently differ from the one derived with it
+	if len(phc.KeyID) > 0 && len(secret) == 0 {
+		return nil, fmt.Errorf("key id %x: %w", phc.KeyID, ErrMissingSecret)
+	}
+	if err := checkKeyLength(length, Argon2MinHashLength, maxUint32, limits); err != nil {
+		return nil, err
+	}
+	return phc.computeHash(ctx, passphrase, secret, uint32(length), true)
+}
+
+// DeriveKey derives a key with the given length from the passphrase, see DeriveKeyContext.
+func (phc *ScryptPHC) DeriveKey(passphrase []byte, length int) ([]byte, error) {
+	return phc.DeriveKeyContext(context.Background(), passphrase, length)
+}
+
+// DeriveKeyContext derives a key with the given length from the passphrase with the parameters and salt of phc.
+// The hash of phc is ignored, the passphrase is normalized as for passwords.
+//
+// The length is restricted by the MaxHashLength of DefaultLimits.
+func (phc *ScryptPHC) DeriveKeyContext(ctx context.Context, passphrase []byte, length int) ([]byte, error) {
+	return phc.deriveKeyLimits(ctx, passphrase, length, DefaultLimits)
+}
+
+func (phc *ScryptPHC) deriveKeyLimits(ctx context.Context, passphrase []byte, length int, limits *Limits) ([]byte, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	if limitErr := limits.CheckScrypt(phc); limitErr != nil {
+		return nil, limitErr
+	}
+	if len(phc.Salt) == 0 {
+		return nil, NewPHCError("can't derive key", ErrMissingSalt)
+	}
+	// the limit from RFC 7914
+	if err := checkKeyLength(length, 1, maxUint32*32, limits); err != nil {
+		return nil, err
+	}
+	return phc.computeHash(ctx, passphrase, length, true)
+}
+
+// DeriveKey decodes the config string with DefaultRegistry and derives a key, see Registry.DeriveKeyContext.
+func DeriveKey(config string, passphrase []byte, length int) ([]byte, error) {
+	return DefaultRegistry.DeriveKeyContext(context.Background(), config, passphrase, length)
+}
+
+// DeriveKeyContext is like DeriveKey, but stops early if ctx is done.
+func DeriveKeyContext(ctx context.Context, config string, passphrase []byte, length int) ([]byte, error) {
+	return DefaultRegistry.DeriveKeyContext(ctx, config, passphrase, length)
+}
+
+// DeriveKeyContext decodes the config string and derives a key with the given length from the passphrase.
+// The decoded hash must implement KeyDeriver (argon2 and scrypt), ErrKDFNotSupported is returned otherwise.
+// The limits of the registry are enforced.
+func (registry *Registry) DeriveKeyContext(ctx context.Context, config string, passphrase []byte, length int) ([]byte, error) {
+	h, err := registry.Decode(config)
+	if err != nil {
+		return nil, err
+	}
+	if limited, ok := h.(limitedKeyDeriver); ok {
+		return limited.deriveKeyLimits(ctx, passphrase, length, registry.limits())
+	}
+	deriver, ok := h.(KeyDeriver)
+	if !ok {
+		return nil, fmt.Errorf("can't use %T: %w", h, ErrKDFNotSupported)
+	}
+	return deriver.DeriveKeyContext(ctx, passphrase, length)
+}
+
+// NewKDFConfig returns a config string for DeriveKey: The parameters of template with a new random salt.
+// The template must be an *Argon2PHC, *ScryptPHC or *Preset, the salt has the length of the template salt (or the
+// length of DefaultSaltGenerator if it is empty).
+func NewKDFConfig(template PasswordHasher) (string, error) {
+	switch v := template.(type) {
+	case *Argon2PHC:
+		res := v.template()
+		if err := res.ValidateParameters(); err != nil {
+			return "", err
+		}
+		salt, saltErr := newKDFSalt(len(v.Salt), res.Variant)
+		if saltErr != nil {
+			return "", saltErr
+		}
+		res.Salt = salt
+		return res.Encode()
+	case *ScryptPHC:
+		res := v.template()
+		salt, saltErr := newKDFSalt(len(v.Salt), "scrypt")
+		if saltErr != nil {
+			return "", saltErr
+		}
+		res.Salt = salt
+		return res.Encode()
+	case *Preset:
+		if v.Argon2 != nil {
+			return NewKDFConfig(v.Argon2)
+		}
+		return NewKDFConfig(v.Scrypt)
+	default:
+		return "", fmt.Errorf("can't use %T: %w", template, ErrKDFNotSupported)
+	}
+}
+
+func newKDFSalt(length int, function string) ([]byte, error) {
+	if length == 0 {
+		length = DefaultSaltGenerator.Length(function)
+	}
+	return DefaultSaltGenerator.GenerateN(length)
+}
@@ -0,0 +1,294 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LDAP userPassword values (RFC 2307) are prefixed with the scheme in curly braces, for example
+// "{SSHA}<base64>", "{CRYPT}$6$..." or "{ARGON2}$argon2id$v=19$...". Scheme names are case insensitive.
+
+var (
+	ErrInvalidLDAPHash   = errors.New("invalid ldap password hash")
+	ErrUnknownLDAPScheme = errors.New("unknown ldap password scheme")
+)
+
+type ldapCodec struct {
+	decode func(payload string) (PasswordHash, error)
+	encode func(h PasswordHash) (string, error)
+}
+
+var ldapCodecs = map[string]ldapCodec{
+	"ARGON2":        {decode: decodeLDAPRegistry(Argon2Variants...), encode: encodeLDAPPayload},
+	"CRYPT":         {decode: decodeLDAPRegistry(), encode: encodeLDAPPayload},
+	"PBKDF2":        {decode: decodeLDAPPBKDF2("pbkdf2-sha1"), encode: encodeLDAPPBKDF2},
+	"PBKDF2-SHA1":   {decode: decodeLDAPPBKDF2("pbkdf2-sha1"), encode: encodeLDAPPBKDF2},
+	"PBKDF2-SHA256": {decode: decodeLDAPPBKDF2("pbkdf2-sha256"), encode: encodeLDAPPBKDF2},
+	"PBKDF2-SHA512": {decode: decodeLDAPPBKDF2("pbkdf2-sha512"), encode: encodeLDAPPBKDF2},
+	"PBKDF2_SHA256": {decode: decode389PBKDF2, encode: encode389PBKDF2},
+	"MD5":           {decode: decodeLDAPDigest("md5", false), encode: encodeLDAPDigest},
+	"SMD5":          {decode: decodeLDAPDigest("md5", true), encode: encodeLDAPDigest},
+	"SHA":           {decode: decodeLDAPDigest("sha1", false), encode: encodeLDAPDigest},
+	"SSHA":          {decode: decodeLDAPDigest("sha1", true), encode: encodeLDAPDigest},
+	"SHA256":        {decode: decodeLDAPDigest("sha256", false), encode: encodeLDAPDigest},
+	"SSHA256":       {decode: decodeLDAPDigest("sha256", true), encode: encodeLDAPDigest},
+	"SHA384":        {decode: decodeLDAPDigest("sha384", false), encode: encodeLDAPDigest},
+	"SSHA384":       {decode: decodeLDAPDigest("sha384", true), encode: encodeLDAPDigest},
+	"SHA512":        {decode: decodeLDAPDigest("sha512", false), encode: encodeLDAPDigest},
+	"SSHA512":       {decode: decodeLDAPDigest("sha512", true), encode: encodeLDAPDigest},
+}
+
+// LDAPSchemes returns all supported scheme names in sorted order.
+func LDAPSchemes() []string {
+	res := make([]string, 0, len(ldapCodecs))
+	for scheme := range ldapCodecs {
+		res = append(res, scheme)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// LDAPPassword is a password hash with a RFC 2307 scheme prefix.
+//
+// Scheme is stored as found in the string, Hash is the decoded hash: *Argon2PHC for {ARGON2}, the hash decoded by
+// the DefaultRegistry for {CRYPT}, *PBKDF2PHC for the PBKDF2 schemes and *DigestHash for the digest schemes.
+type LDAPPassword struct {
+	Scheme string
+	Hash   PasswordHash
+}
+
+func newInvalidLDAPHashError(message string) error {
+	return fmt.Errorf("%s: %w", message, ErrInvalidLDAPHash)
+}
+
+// SplitLDAPScheme splits "{scheme}rest" into scheme and rest.
+func SplitLDAPScheme(s string) (string, string, error) {
+	if !strings.HasPrefix(s, "{") {
+		return "", "", newInvalidLDAPHashError("value must begin with \"{\"")
+	}
+	end := strings.IndexRune(s, '}')
+	if end < 0 {
+		return "", "", newInvalidLDAPHashError("no closing \"}\" found")
+	}
+	return s[1:end], s[end+1:], nil
+}
+
+func DecodeLDAP(s string) (*LDAPPassword, error) {
+	if limitErr := DefaultLimits.CheckString(s); limitErr != nil {
+		return nil, limitErr
+	}
+	scheme, payload, splitErr := SplitLDAPScheme(s)
+	if splitErr != nil {
+		return nil, splitErr
+	}
+	codec, has := ldapCodecs[strings.ToUpper(scheme)]
+	if !has {
+		return nil, fmt.Errorf("scheme \"%s\": %w", scheme, ErrUnknownLDAPScheme)
+	}
+	h, err := codec.decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	if limitErr := DefaultLimits.Check(h); limitErr != nil {
+		return nil, limitErr
+	}
+	return &LDAPPassword{Scheme: scheme, Hash: h}, nil
+}
+
+// Encode returns the value with its scheme prefix.
+func (p *LDAPPassword) Encode() (string, error) {
+	codec, has := ldapCodecs[strings.ToUpper(p.Scheme)]
+	if !has {
+		return "", fmt.Errorf("scheme \"%s\": %w", p.Scheme, ErrUnknownLDAPScheme)
+	}
+	payload, err := codec.encode(p.Hash)
+	if err != nil {
+		return "", err
+	}
+	return "{" + p.Scheme + "}" + payload, nil
+}
+
+// Verify checks if the password matches the hash.
+func (p *LDAPPassword) Verify(password []byte) (bool, error) {
+	return p.Hash.Verify(password)
+}
+
+// VerifyContext is like Verify, but stops early if ctx is done, see VerifyContext.
+func (p *LDAPPassword) VerifyContext(ctx context.Context, password []byte) (bool, error) {
+	return VerifyContext(ctx, p.Hash, password)
+}
+
+func (p *LDAPPassword) verifyLimits(ctx context.Context, password []byte, limits *Limits) (bool, error) {
+	return verifyWithLimits(ctx, p.Hash, password, limits)
+}
+
+// decodeLDAPRegistry returns a decoder that decodes the payload with the DefaultRegistry, if functionNames is not
+// empty the function of the payload must be one of these names.
+func decodeLDAPRegistry(functionNames ...string) func(payload string) (PasswordHash, error) {
+	return func(payload string) (PasswordHash, error) {
+		if len(functionNames) > 0 {
+			functionName, nameErr := FunctionName(payload)
+			if nameErr != nil {
+				return nil, nameErr
+			}
+			found := false
+			for _, candidate := range functionNames {
+				if candidate == functionName {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, NewMismatchedFunctionNameError(functionName, functionNames...)
+			}
+		}
+		return DefaultRegistry.Decode(payload)
+	}
+}
+
+func encodeLDAPPayload(h PasswordHash) (string, error) {
+	return h.Encode()
+}
+
+// the passlib / Dovecot format: <rounds>$<salt>$<hash> where salt and hash are base64 encoded with "." instead
+// of "+" and without padding
+var ldapAdaptedBase64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./").WithPadding(base64.NoPadding)
+
+func decodeLDAPPBKDF2(variant string) func(payload string) (PasswordHash, error) {
+	return func(payload string) (PasswordHash, error) {
+		split := strings.Split(payload, "$")
+		if len(split) != 3 {
+			return nil, newInvalidLDAPHashError("pbkdf2 hash must be of the form <rounds>$<salt>$<hash>")
+		}
+		rounds, roundsErr := decodeNoneZeroUnsignedString(split[0], false, 31)
+		if roundsErr != nil {
+			return nil, wrapParameterValueErrorToPHCError("can't parse as integer", "rounds", roundsErr)
+		}
+		salt, saltErr := ldapAdaptedBase64.DecodeString(split[1])
+		if saltErr != nil {
+			return nil, NewPHCError("error decoding salt from base64 string", newBase64DecodeErrorWrapper(saltErr))
+		}
+		hash, hashErr := ldapAdaptedBase64.DecodeString(split[2])
+		if hashErr != nil {
+			return nil, NewPHCError("error decoding hash from base64", newBase64DecodeErrorWrapper(hashErr))
+		}
+		return newLDAPPBKDF2PHC(variant, int(rounds), salt, hash), nil
+	}
+}
+
+func newLDAPPBKDF2PHC(variant string, iterations int, salt, hash []byte) *PBKDF2PHC {
+	return &PBKDF2PHC{
+		Variant:    variant,
+		Iterations: iterations,
+		Salt:       salt,
+		SaltString: string(Base64Encode(salt)),
+		Hash:       hash,
+		HashString: string(Base64Encode(hash)),
+	}
+}
+
+func encodeLDAPPBKDF2(h PasswordHash) (string, error) {
+	phc, ok := h.(*PBKDF2PHC)
+	if !ok {
+		return "", newInvalidLDAPHashError("pbkdf2 hash must be of type *PBKDF2PHC")
+	}
+	if err := phc.ValidateParameters(); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(phc.Iterations) + "$" + ldapAdaptedBase64.EncodeToString(phc.Salt) + "$" +
+		ldapAdaptedBase64.EncodeToString(phc.Hash), nil
+}
+
+// 389 Directory Server stores {PBKDF2_SHA256} as base64 of iterations (4 bytes, big endian) | salt | hash
+const (
+	ds389PBKDF2SaltLength = 64
+	ds389PBKDF2HashLength = 256
+)
+
+func decode389PBKDF2(payload string) (PasswordHash, error) {
+	blob, decodeErr := base64.StdEncoding.DecodeString(payload)
+	if decodeErr != nil {
+		return nil, NewPHCError("error decoding pbkdf2 hash from base64", newBase64DecodeErrorWrapper(decodeErr))
+	}
+	if len(blob) != 4+ds389PBKDF2SaltLength+ds389PBKDF2HashLength {
+		return nil, newInvalidLDAPHashError(fmt.Sprintf("pbkdf2 hash must have length %d, got %d",
+			4+ds389PBKDF2SaltLength+ds389PBKDF2HashLength, len(blob)))
+	}
+	iterations := binary.BigEndian.Uint32(blob[:4])
+	if iterations < 1 || iterations > uint32(maxInt32) {
+		return nil, wrapParameterValueErrorToPHCError(fmt.Sprintf("invalid iteration count %d", iterations), "rounds", nil)
+	}
+	salt := blob[4 : 4+ds389PBKDF2SaltLength]
+	hash := blob[4+ds389PBKDF2SaltLength:]
+	return newLDAPPBKDF2PHC("pbkdf2-sha256", int(iterations), salt, hash), nil
+}
+
+func encode389PBKDF2(h PasswordHash) (string, error) {
+	phc, ok := h.(*PBKDF2PHC)
+	if !ok {
+		return "", newInvalidLDAPHashError("pbkdf2 hash must be of type *PBKDF2PHC")
+	}
+	if err := phc.ValidateParameters(); err != nil {
+		return "", err
+	}
+	if phc.Variant != "pbkdf2-sha256" || len(phc.Salt) != ds389PBKDF2SaltLength || len(phc.Hash) != ds389PBKDF2HashLength {
+		return "", newInvalidLDAPHashError("hash can't be represented as {PBKDF2_SHA256}")
+	}
+	blob := make([]byte, 4, 4+ds389PBKDF2SaltLength+ds389PBKDF2HashLength)
+	binary.BigEndian.PutUint32(blob, uint32(phc.Iterations))
+	blob = append(blob, phc.Salt...)
+	blob = append(blob, phc.Hash...)
+	return base64.StdEncoding.EncodeToString(blob), nil
+}
+
+func decodeLDAPDigest(algorithm string, salted bool) func(payload string) (PasswordHash, error) {
+	return func(payload string) (PasswordHash, error) {
+		blob, decodeErr := base64.StdEncoding.DecodeString(payload)
+		if decodeErr != nil {
+			return nil, NewPHCError("error decoding digest from base64", newBase64DecodeErrorWrapper(decodeErr))
+		}
+		size := digestHashFunc(algorithm)().Size()
+		if len(blob) < size || (!salted && len(blob) != size) {
+			return nil, newInvalidLDAPHashError(fmt.Sprintf("invalid length %d of %s digest", len(blob), algorithm))
+		}
+		res := &DigestHash{
+			Algorithm: algorithm,
+			Hash:      blob[:size],
+		}
+		if salted {
+			res.Salt = blob[size:]
+		}
+		return res, nil
+	}
+}
+
+func encodeLDAPDigest(h PasswordHash) (string, error) {
+	digest, ok := h.(*DigestHash)
+	if !ok {
+		return "", newInvalidLDAPHashError("digest must be of type *DigestHash")
+	}
+	if err := digest.ValidateParameters(); err != nil {
+		return "", err
+	}
+	return digest.encodePayload(), nil
+}
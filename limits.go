@@ -0,0 +1,303 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrResourceLimitExceeded = errors.New("resource limit exceeded")
+)
+
+// In all limits a value of 0 means that there is no limit.
+
+type Argon2Limits struct {
+	// MaxMemory is the maximum memory in KiB (the parameter m)
+	MaxMemory      uint32
+	MaxIterations  uint32
+	MaxParallelism uint32
+}
+
+type ScryptLimits struct {
+	// MaxMemory is the maximum memory in bytes, estimated as 128 * r * (N + p)
+	MaxMemory      uint64
+	MaxCost        int
+	MaxBlockSize   int
+	MaxParallelism int
+}
+
+type PBKDF2Limits struct {
+	MaxIterations int
+}
+
+type SHACryptLimits struct {
+	MaxRounds int
+}
+
+type BcryptLimits struct {
+	MaxCost int
+}
+
+// Limits restricts the resources a hash may use, they're used to protect against malicious hashes that would
+// require huge amounts of memory or time to verify.
+//
+// The string limits (MaxStringLength and MaxParameters) are applied before a string is decoded, the other limits
+// after decoding and again before a hash is computed.
+type Limits struct {
+	MaxStringLength int
+	// MaxParameters is the maximum number of parameters, approximated as the number of '=' in the string
+	MaxParameters int
+	MaxSaltLength int
+	MaxHashLength int
+	Argon2        Argon2Limits
+	Scrypt        ScryptLimits
+	PBKDF2        PBKDF2Limits
+	SHACrypt      SHACryptLimits
+	Bcrypt        BcryptLimits
+}
+
+// NewDefaultLimits returns limits that allow all commonly recommended parameters (for example the first
+// recommendation of RFC 9106 with 2 GiB of memory), but not much more.
+func NewDefaultLimits() *Limits {
+	return &Limits{
+		MaxStringLength: 4096,
+		MaxParameters:   16,
+		MaxSaltLength:   1024,
+		MaxHashLength:   1024,
+		Argon2: Argon2Limits{
+			MaxMemory:      2 * 1024 * 1024,
+			MaxIterations:  64,
+			MaxParallelism: 64,
+		},
+		Scrypt: ScryptLimits{
+			MaxMemory:      2 * 1024 * 1024 * 1024,
+			MaxCost:        1 << 24,
+			MaxBlockSize:   64,
+			MaxParallelism: 64,
+		},
+		PBKDF2: PBKDF2Limits{
+			MaxIterations: 10000000,
+		},
+		SHACrypt: SHACryptLimits{
+			MaxRounds: 10000000,
+		},
+		Bcrypt: BcryptLimits{
+			MaxCost: 20,
+		},
+	}
+}
+
+// DefaultLimits are the limits enforced by all decode functions and before computing a hash, a Registry uses its
+// own Limits if set. It can be set to nil to disable all limits, it should not be changed while hashes are decoded
+// or verified.
+var DefaultLimits = NewDefaultLimits()
+
+func newResourceLimitError(parameterName string, value, limit uint64) error {
+	return wrapParameterValueErrorToPHCError(fmt.Sprintf("value %d exceeds limit %d", value, limit), parameterName,
+		ErrResourceLimitExceeded)
+}
+
+// exceeds returns true if limit is set and value > limit.
+func exceeds(value, limit uint64) bool {
+	return limit > 0 && value > limit
+}
+
+// CheckString checks the length and the number of parameters of s.
+func (limits *Limits) CheckString(s string) error {
+	if limits == nil {
+		return nil
+	}
+	if exceeds(uint64(len(s)), uint64(limits.MaxStringLength)) {
+		return NewPHCError(fmt.Sprintf("string length %d exceeds limit %d", len(s), limits.MaxStringLength),
+			ErrResourceLimitExceeded)
+	}
+	if numParameters := strings.Count(s, "="); exceeds(uint64(numParameters), uint64(limits.MaxParameters)) {
+		return NewPHCError(fmt.Sprintf("number of parameters %d exceeds limit %d", numParameters, limits.MaxParameters),
+			ErrResourceLimitExceeded)
+	}
+	return nil
+}
+
+func (limits *Limits) checkSaltAndHash(salt, hash []byte) error {
+	if exceeds(uint64(len(salt)), uint64(limits.MaxSaltLength)) {
+		return newResourceLimitError("salt", uint64(len(salt)), uint64(limits.MaxSaltLength))
+	}
+	if exceeds(uint64(len(hash)), uint64(limits.MaxHashLength)) {
+		return newResourceLimitError("hash", uint64(len(hash)), uint64(limits.MaxHashLength))
+	}
+	return nil
+}
+
+func (limits *Limits) CheckArgon2(phc *Argon2PHC) error {
+	if limits == nil {
+		return nil
+	}
+	if exceeds(uint64(phc.M), uint64(limits.Argon2.MaxMemory)) {
+		return newResourceLimitError("m", uint64(phc.M), uint64(limits.Argon2.MaxMemory))
+	}
+	if exceeds(uint64(phc.T), uint64(limits.Argon2.MaxIterations)) {
+		return newResourceLimitError("t", uint64(phc.T), uint64(limits.Argon2.MaxIterations))
+	}
+	if exceeds(uint64(phc.P), uint64(limits.Argon2.MaxParallelism)) {
+		return newResourceLimitError("p", uint64(phc.P), uint64(limits.Argon2.MaxParallelism))
+	}
+	return limits.checkSaltAndHash(phc.Salt, phc.Hash)
+}
+
+// scryptMemory estimates the memory used by scrypt in bytes: 128 * r * (N + p).
+// The result is saturated at the maximum uint64 value.
+func scryptMemory(cost, blockSize, parallelism int) uint64 {
+	const maxUint64 = ^uint64(0)
+	n := uint64(cost) + uint64(parallelism)
+	r := uint64(blockSize)
+	if r == 0 || n == 0 {
+		return 0
+	}
+	if n > maxUint64/128/r {
+		return maxUint64
+	}
+	return 128 * r * n
+}
+
+func (limits *Limits) checkScryptParameters(cost, blockSize, parallelism int, costName, blockSizeName string) error {
+	// MaxCost limits N and not its logarithm, so the error is reported for N
+	if exceeds(uint64(cost), uint64(limits.Scrypt.MaxCost)) {
+		return newResourceLimitError("N", uint64(cost), uint64(limits.Scrypt.MaxCost))
+	}
+	if exceeds(uint64(blockSize), uint64(limits.Scrypt.MaxBlockSize)) {
+		return newResourceLimitError(blockSizeName, uint64(blockSize), uint64(limits.Scrypt.MaxBlockSize))
+	}
+	if exceeds(uint64(parallelism), uint64(limits.Scrypt.MaxParallelism)) {
+		return newResourceLimitError("p", uint64(parallelism), uint64(limits.Scrypt.MaxParallelism))
+	}
+	if memory := scryptMemory(cost, blockSize, parallelism); exceeds(memory, limits.Scrypt.MaxMemory) {
+		return wrapMultipleParametersValueErrorToPHCError(fmt.Sprintf("memory %d exceeds limit %d", memory, limits.Scrypt.MaxMemory),
+			ErrResourceLimitExceeded, costName, blockSizeName, "p")
+	}
+	return nil
+}
+
+func (limits *Limits) CheckScrypt(phc *ScryptPHC) error {
+	if limits == nil {
+		return nil
+	}
+	if err := limits.checkScryptParameters(phc.Cost, phc.BlockSize, phc.Parallelism, "ln", "r"); err != nil {
+		return err
+	}
+	return limits.checkSaltAndHash(phc.Salt, phc.Hash)
+}
+
+// CheckFirebaseScrypt checks the scrypt limits (N = 2^mem-cost, r = rounds, p = 1).
+func (limits *Limits) CheckFirebaseScrypt(phc *FirebaseScryptPHC) error {
+	if limits == nil {
+		return nil
+	}
+	// mem-cost is validated to be small, but check it here again to avoid an overflow
+	if phc.MemCost < 0 || phc.MemCost > 62 {
+		return newResourceLimitError("mem-cost", uint64(phc.MemCost), 62)
+	}
+	if err := limits.checkScryptParameters(1<<uint(phc.MemCost), phc.Rounds, 1, "mem-cost", "rounds"); err != nil {
+		return err
+	}
+	return limits.checkSaltAndHash(phc.Salt, phc.Hash)
+}
+
+func (limits *Limits) CheckPBKDF2(phc *PBKDF2PHC) error {
+	if limits == nil {
+		return nil
+	}
+	if exceeds(uint64(phc.Iterations), uint64(limits.PBKDF2.MaxIterations)) {
+		return newResourceLimitError("i", uint64(phc.Iterations), uint64(limits.PBKDF2.MaxIterations))
+	}
+	return limits.checkSaltAndHash(phc.Salt, phc.Hash)
+}
+
+func (limits *Limits) CheckSHACrypt(h *SHACryptHash) error {
+	if limits == nil {
+		return nil
+	}
+	if exceeds(uint64(h.Rounds), uint64(limits.SHACrypt.MaxRounds)) {
+		return newResourceLimitError("rounds", uint64(h.Rounds), uint64(limits.SHACrypt.MaxRounds))
+	}
+	return nil
+}
+
+func (limits *Limits) CheckBcrypt(h *BcryptHash) error {
+	if limits == nil {
+		return nil
+	}
+	if exceeds(uint64(h.Cost), uint64(limits.Bcrypt.MaxCost)) {
+		return newResourceLimitError("cost", uint64(h.Cost), uint64(limits.Bcrypt.MaxCost))
+	}
+	return nil
+}
+
+// CheckOnion checks the argon2 limits of the outer hash and the pbkdf2 limits of the inner hash.
+func (limits *Limits) CheckOnion(phc *OnionPHC) error {
+	if limits == nil {
+		return nil
+	}
+	if exceeds(uint64(phc.InnerRounds), uint64(limits.PBKDF2.MaxIterations)) {
+		return newResourceLimitError("irounds", uint64(phc.InnerRounds), uint64(limits.PBKDF2.MaxIterations))
+	}
+	if err := limits.checkSaltAndHash(phc.InnerSalt, nil); err != nil {
+		return err
+	}
+	if exceeds(uint64(phc.InnerLength), uint64(limits.MaxHashLength)) {
+		return newResourceLimitError("ilen", uint64(phc.InnerLength), uint64(limits.MaxHashLength))
+	}
+	return limits.CheckArgon2(phc.Outer)
+}
+
+func (limits *Limits) CheckDigest(h *DigestHash) error {
+	if limits == nil {
+		return nil
+	}
+	return limits.checkSaltAndHash(h.Salt, h.Hash)
+}
+
+// Check checks the limits for all hashes implemented in this package, wrapped hashes (LDAP, Spring, envelopes) are
+// checked as well. Unknown hashes are not checked.
+func (limits *Limits) Check(h PasswordHash) error {
+	switch v := h.(type) {
+	case *Argon2PHC:
+		return limits.CheckArgon2(v)
+	case *ScryptPHC:
+		return limits.CheckScrypt(v)
+	case *FirebaseScryptPHC:
+		return limits.CheckFirebaseScrypt(v)
+	case *PBKDF2PHC:
+		return limits.CheckPBKDF2(v)
+	case *SHACryptHash:
+		return limits.CheckSHACrypt(v)
+	case *BcryptHash:
+		return limits.CheckBcrypt(v)
+	case *DigestHash:
+		return limits.CheckDigest(v)
+	case *OnionPHC:
+		return limits.CheckOnion(v)
+	case *LDAPPassword:
+		return limits.Check(v.Hash)
+	case *SpringPasswordHash:
+		return limits.Check(v.Hash)
+	case *EnvelopeHash:
+		return limits.Check(v.Hash)
+	default:
+		return nil
+	}
+}
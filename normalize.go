@@ -0,0 +1,121 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/secure/precis"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrUnknownNormalization = errors.New("unknown normalization profile")
+	ErrNormalization        = errors.New("password can't be normalized")
+)
+
+// Normalization profiles for passwords, they're stored in the norm parameter of argon2 and scrypt hashes.
+const (
+	// NormalizationNone hashes the password bytes as they are, this is the default
+	NormalizationNone = ""
+	// NormalizationNFC applies the Unicode normalization form C
+	NormalizationNFC = "nfc"
+	// NormalizationNFKC applies the Unicode normalization form KC
+	NormalizationNFKC = "nfkc"
+	// NormalizationOpaqueString applies the OpaqueString profile from RFC 8265 (the successor of SASLprep),
+	// passwords with control characters or empty passwords are rejected
+	NormalizationOpaqueString = "opaque"
+)
+
+// NormalizationProfiles contains all normalization profiles except NormalizationNone.
+var NormalizationProfiles = []string{NormalizationNFC, NormalizationNFKC, NormalizationOpaqueString}
+
+func isValidNormalization(profile string) bool {
+	if profile == NormalizationNone {
+		return true
+	}
+	for _, valid := range NormalizationProfiles {
+		if profile == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func validateNormalization(profile string) error {
+	if !isValidNormalization(profile) {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("unknown profile %q", profile), "norm", ErrUnknownNormalization)
+	}
+	return nil
+}
+
+// NormalizePassword applies the normalization profile to the password.
+// For NormalizationNone the password is returned unchanged, otherwise a new slice is returned.
+//
+// The password must be valid UTF-8 for all other profiles, ErrNormalization is returned otherwise.
+func NormalizePassword(profile string, password []byte) ([]byte, error) {
+	if profile == NormalizationNone {
+		return password, nil
+	}
+	if !utf8.Valid(password) {
+		return nil, fmt.Errorf("profile %s: invalid UTF-8: %w", profile, ErrNormalization)
+	}
+	switch profile {
+	case NormalizationNFC:
+		return norm.NFC.Append(nil, password...), nil
+	case NormalizationNFKC:
+		return norm.NFKC.Append(nil, password...), nil
+	case NormalizationOpaqueString:
+		res, err := precis.OpaqueString.Bytes(password)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %v: %w", profile, err, ErrNormalization)
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("profile %q: %w", profile, ErrUnknownNormalization)
+	}
+}
+
+// PasswordNormalization returns the normalization profile of h, LDAP, Spring and envelope hashes are unwrapped.
+// NormalizationNone is returned if h doesn't support normalization.
+func PasswordNormalization(h PasswordHash) string {
+	switch v := h.(type) {
+	case *Argon2PHC:
+		return v.Normalization
+	case *ScryptPHC:
+		return v.Normalization
+	case *LDAPPassword:
+		return PasswordNormalization(v.Hash)
+	case *SpringPasswordHash:
+		return PasswordNormalization(v.Hash)
+	case *EnvelopeHash:
+		return PasswordNormalization(v.Hash)
+	default:
+		return NormalizationNone
+	}
+}
+
+// normalizeIf normalizes the password if normalize is true, it is false if the normalized password was
+// transformed before it is hashed (for example by a pepper).
+// wipe is true if the returned slice is a new slice that should be wiped after use.
+func normalizeIf(normalize bool, profile string, password []byte) (res []byte, wipe bool, err error) {
+	if !normalize || profile == NormalizationNone {
+		return password, false, nil
+	}
+	res, err = NormalizePassword(profile, password)
+	return res, err == nil, err
+}
@@ -0,0 +1,354 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// OnionPrefix is the prefix of the function names of onion hashes, the inner algorithm follows the prefix,
+// for example "onion-md5" or "onion-pbkdf2-sha256".
+const OnionPrefix = "onion-"
+
+// the outer hash of an onion hash is always argon2id
+const onionOuterVariant = "argon2id"
+
+// OnionInnerAlgorithms are the legacy algorithms that can be wrapped in an onion hash.
+var OnionInnerAlgorithms = append(append([]string{}, DigestAlgorithms...), PBKDF2Variants...)
+
+// OnionFunctionNames returns the function names of all onion hashes.
+func OnionFunctionNames() []string {
+	res := make([]string, len(OnionInnerAlgorithms))
+	for i, inner := range OnionInnerAlgorithms {
+		res[i] = OnionPrefix + inner
+	}
+	return res
+}
+
+func isPBKDF2Variant(s string) bool {
+	return pbkdf2HashFunc(s) != nil
+}
+
+// OnionSchema describes onion hashes: The argon2 parameters v, m, t and p followed by the parameters of the inner
+// hash: isalt (the base64 encoded salt), irounds (the pbkdf2 iterations) and ilen (the pbkdf2 key length).
+//
+// Example: $onion-pbkdf2-sha256$v=19$m=65536,t=3,p=4,isalt=c2FsdA,irounds=10000,ilen=32$<salt>$<hash>
+var OnionSchema = &PHCSchema{
+	FunctionNames: OnionFunctionNames(),
+	ParameterDescriptions: []*PHCParameterDescription{
+		{
+			Name:          "v",
+			Default:       strconv.FormatUint(uint64(defaultArgon2Version), 10),
+			Optional:      true,
+			ValidateValue: NoValueValidator,
+		},
+		{
+			Name:          "m",
+			Default:       "",
+			Optional:      false,
+			ValidateValue: NoValueValidator,
+		},
+		{
+			Name:          "t",
+			Default:       "",
+			Optional:      false,
+			ValidateValue: NoValueValidator,
+		},
+		{
+			Name:          "p",
+			Default:       "",
+			Optional:      false,
+			ValidateValue: NoValueValidator,
+		},
+		{
+			Name:          "isalt",
+			Default:       "",
+			Optional:      true,
+			ValidateValue: ValueCharacterValidator,
+		},
+		{
+			Name:          "irounds",
+			Default:       "",
+			Optional:      true,
+			ValidateValue: NoValueValidator,
+		},
+		{
+			Name:          "ilen",
+			Default:       "",
+			Optional:      true,
+			ValidateValue: NoValueValidator,
+		},
+	},
+	Decoder: DefaultBase64,
+	Encoder: DefaultBase64,
+}
+
+// OnionPHC stores argon2id(legacy_hash(password)), it is used to upgrade legacy hashes without knowing the
+// passwords.
+//
+// Inner is one of OnionInnerAlgorithms. For digests the inner hash is H(password || InnerSalt), for pbkdf2 it is
+// the key of length InnerLength computed with InnerRounds iterations and InnerSalt.
+// Outer is the argon2id hash of the (binary) inner hash.
+type OnionPHC struct {
+	Inner       string
+	InnerSalt   []byte
+	InnerRounds int
+	InnerLength int
+	Outer       *Argon2PHC
+}
+
+func (phc *OnionPHC) ValidateParameters() error {
+	switch {
+	case isPBKDF2Variant(phc.Inner):
+		if phc.InnerRounds < 1 {
+			return wrapParameterValueErrorToPHCError("must be > 0", "irounds", nil)
+		}
+		if phc.InnerLength < 1 {
+			return wrapParameterValueErrorToPHCError("must be > 0", "ilen", nil)
+		}
+	case digestHashFunc(phc.Inner) != nil:
+		if phc.InnerRounds != 0 || phc.InnerLength != 0 {
+			return wrapMultipleParametersValueErrorToPHCError("not allowed for digests", nil, "irounds", "ilen")
+		}
+	default:
+		return NewMismatchedFunctionNameError(OnionPrefix+phc.Inner, OnionFunctionNames()...)
+	}
+	if phc.Outer == nil {
+		return NewPHCError("onion hash has no outer hash", nil)
+	}
+	if phc.Outer.Variant != onionOuterVariant {
+		return NewMismatchedFunctionNameError(phc.Outer.Variant, onionOuterVariant)
+	}
+	// the inner hash is binary, it can't be normalized
+	if phc.Outer.Normalization != NormalizationNone {
+		return wrapParameterValueErrorToPHCError("not allowed for onion hashes", "norm", nil)
+	}
+	// the onion string has no keyid, data and pid parameters
+	if len(phc.Outer.KeyID) > 0 || len(phc.Outer.Data) > 0 || len(phc.Outer.PepperID) > 0 {
+		return wrapMultipleParametersValueErrorToPHCError("not allowed for onion hashes", nil, "keyid", "data", "pid")
+	}
+	return phc.Outer.ValidateParameters()
+}
+
+func parseOnionInt(param ParameterValuePair) (int, error) {
+	if !param.IsSet {
+		return 0, nil
+	}
+	res, err := decodeNoneZeroUnsignedString(param.Value, false, 31)
+	if err != nil {
+		return 0, wrapParameterValueErrorToPHCError("can't parse as integer", param.Name, err)
+	}
+	return int(res), nil
+}
+
+func DecodeOnion(phcString string) (*OnionPHC, error) {
+	return decodeOnion(phcString, DefaultLimits)
+}
+
+func decodeOnion(phcString string, limits *Limits) (*OnionPHC, error) {
+	if limitErr := limits.CheckString(phcString); limitErr != nil {
+		return nil, limitErr
+	}
+	instance, err := OnionSchema.Decode(phcString)
+	if err != nil {
+		return nil, err
+	}
+	// just an assertion, should never happen
+	if len(instance.Parameters) != 7 {
+		return nil, fmt.Errorf("internal error: expected exactly 7 parameters, got %d instead", len(instance.Parameters))
+	}
+	params := instance.Parameters
+	outer, outerErr := argon2FromStringParams(onionOuterVariant, params[0], params[1], params[2], params[3],
+		ParameterValuePair{Name: "keyid"}, ParameterValuePair{Name: "data"},
+		instance.Salt, instance.Hash, instance.SaltString, instance.HashString)
+	if outerErr != nil {
+		return nil, outerErr
+	}
+	var innerSalt []byte
+	if params[4].IsSet {
+		var saltErr error
+		innerSalt, saltErr = OnionSchema.decodeBase64(params[4].Value)
+		if saltErr != nil {
+			return nil, wrapParameterValueErrorToPHCError("can't decode base64", params[4].Name, saltErr)
+		}
+	}
+	rounds, roundsErr := parseOnionInt(params[5])
+	if roundsErr != nil {
+		return nil, roundsErr
+	}
+	length, lengthErr := parseOnionInt(params[6])
+	if lengthErr != nil {
+		return nil, lengthErr
+	}
+	res := &OnionPHC{
+		Inner:       strings.TrimPrefix(instance.Function, OnionPrefix),
+		InnerSalt:   innerSalt,
+		InnerRounds: rounds,
+		InnerLength: length,
+		Outer:       outer,
+	}
+	if err := res.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	if limitErr := limits.CheckOnion(res); limitErr != nil {
+		return nil, limitErr
+	}
+	return res, nil
+}
+
+// Encode returns the phc string of the instance.
+func (phc *OnionPHC) Encode() (string, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return "", err
+	}
+	version := ParameterValuePair{Name: "v"}
+	if phc.Outer.Version != defaultArgon2Version {
+		version.Value = strconv.FormatUint(uint64(phc.Outer.Version), 10)
+		version.IsSet = true
+	}
+	instance := &PHCInstance{
+		Function: OnionPrefix + phc.Inner,
+		Parameters: []ParameterValuePair{
+			version,
+			{Name: "m", Value: strconv.FormatUint(uint64(phc.Outer.M), 10), IsSet: true},
+			{Name: "t", Value: strconv.FormatUint(uint64(phc.Outer.T), 10), IsSet: true},
+			{Name: "p", Value: strconv.FormatUint(uint64(phc.Outer.P), 10), IsSet: true},
+			{Name: "isalt", Value: OnionSchema.encodeBase64(phc.InnerSalt), IsSet: len(phc.InnerSalt) > 0},
+			{Name: "irounds", Value: strconv.Itoa(phc.InnerRounds), IsSet: phc.InnerRounds > 0},
+			{Name: "ilen", Value: strconv.Itoa(phc.InnerLength), IsSet: phc.InnerLength > 0},
+		},
+		Salt: phc.Outer.Salt,
+		Hash: phc.Outer.Hash,
+	}
+	return OnionSchema.Encode(instance)
+}
+
+// innerHash computes the legacy hash of the password.
+func (phc *OnionPHC) innerHash(password []byte) []byte {
+	if isPBKDF2Variant(phc.Inner) {
+		return pbkdf2.Key(password, phc.InnerSalt, phc.InnerRounds, phc.InnerLength, pbkdf2HashFunc(phc.Inner))
+	}
+	digest := &DigestHash{Algorithm: phc.Inner, Salt: phc.InnerSalt}
+	return digest.digest(password)
+}
+
+// Verify checks if the password matches the hash, the inner hash is computed first.
+func (phc *OnionPHC) Verify(password []byte) (bool, error) {
+	return phc.VerifyContext(context.Background(), password)
+}
+
+// VerifyContext is like Verify, but stops early if ctx is done, see Argon2KeyContext.
+func (phc *OnionPHC) VerifyContext(ctx context.Context, password []byte) (bool, error) {
+	return phc.verifyLimits(ctx, password, DefaultLimits)
+}
+
+func (phc *OnionPHC) verifyLimits(ctx context.Context, password []byte, limits *Limits) (bool, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return false, err
+	}
+	if limitErr := limits.CheckOnion(phc); limitErr != nil {
+		return false, limitErr
+	}
+	inner := phc.innerHash(password)
+	defer wipeBytes(inner)
+	return phc.Outer.verifyLimits(ctx, inner, limits)
+}
+
+// WrapLegacyHash wraps a legacy hash in an onion hash, the outer hash is computed with the argon2id template.
+//
+// legacy must be a *DigestHash or *PBKDF2PHC (LDAP hashes are unwrapped), for example decoded with
+// DecodeHexDigest or DecodeLDAP.
+func WrapLegacyHash(template *Argon2PHC, legacy PasswordHash) (*OnionPHC, error) {
+	if template.Variant != onionOuterVariant {
+		return nil, NewMismatchedFunctionNameError(template.Variant, onionOuterVariant)
+	}
+	res := &OnionPHC{}
+	var innerValue []byte
+	switch v := legacy.(type) {
+	case *LDAPPassword:
+		return WrapLegacyHash(template, v.Hash)
+	case *DigestHash:
+		if err := v.ValidateParameters(); err != nil {
+			return nil, err
+		}
+		res.Inner = v.Algorithm
+		res.InnerSalt = v.Salt
+		innerValue = v.Hash
+	case *PBKDF2PHC:
+		if err := v.ValidateParameters(); err != nil {
+			return nil, err
+		}
+		res.Inner = v.Variant
+		res.InnerSalt = v.Salt
+		res.InnerRounds = v.Iterations
+		res.InnerLength = len(v.Hash)
+		innerValue = v.Hash
+	default:
+		return nil, fmt.Errorf("can't wrap hash of type %T", legacy)
+	}
+	// the normalization of the template doesn't apply to the binary inner hash, keyid, data and pid can't be
+	// encoded in the onion string
+	outerTemplate := *template
+	outerTemplate.Normalization = NormalizationNone
+	outerTemplate.KeyID, outerTemplate.Data, outerTemplate.PepperID = nil, nil, nil
+	outer, err := outerTemplate.HashPassword(innerValue)
+	if err != nil {
+		return nil, err
+	}
+	res.Outer = outer
+	if err := res.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// OnionConversion is the result of converting a single legacy string with ConvertLegacyHashes.
+type OnionConversion struct {
+	Legacy string
+	// Onion is the phc string of the onion hash, it is empty if Err is not nil
+	Onion string
+	Err   error
+}
+
+// ConvertLegacyHashes decodes each legacy string with decoder and wraps it with WrapLegacyHash.
+// If decoder is nil DefaultRegistry.Decode is used.
+//
+// An error in one string doesn't stop the conversion, the error is reported in the corresponding result.
+func ConvertLegacyHashes(template *Argon2PHC, decoder DecoderFunc, legacy []string) []OnionConversion {
+	if decoder == nil {
+		decoder = DefaultRegistry.Decode
+	}
+	res := make([]OnionConversion, len(legacy))
+	for i, s := range legacy {
+		res[i].Legacy = s
+		h, err := decoder(s)
+		if err != nil {
+			res[i].Err = err
+			continue
+		}
+		onion, wrapErr := WrapLegacyHash(template, h)
+		if wrapErr != nil {
+			res[i].Err = wrapErr
+			continue
+		}
+		res[i].Onion, res[i].Err = onion.Encode()
+	}
+	return res
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrPasswordWiped = errors.New("password has been wiped")
+)
+
+const redacted = "[REDACTED]"
+
+// Password holds a secret password, it is never printed by fmt or log and can be wiped explicitly.
+//
+// Wipe should be called (usually with defer) once the password is no longer needed. Go doesn't guarantee that no
+// other copies exist (for example a string the password was created from), but the bytes held by the Password
+// don't linger on the heap.
+type Password struct {
+	b     []byte
+	wiped bool
+}
+
+// NewPassword returns a password holding a copy of b, b can be wiped afterwards.
+func NewPassword(b []byte) *Password {
+	copied := make([]byte, len(b))
+	copy(copied, b)
+	return &Password{b: copied}
+}
+
+// NewPasswordString returns a password holding the bytes of s.
+// Note that s itself can't be wiped, so prefer NewPassword if the password is available as a byte slice.
+func NewPasswordString(s string) *Password {
+	return &Password{b: []byte(s)}
+}
+
+// Bytes returns the password, the slice must not be retained or modified. It returns ErrPasswordWiped if the
+// password has been wiped.
+func (p *Password) Bytes() ([]byte, error) {
+	if p == nil || p.wiped {
+		return nil, ErrPasswordWiped
+	}
+	return p.b, nil
+}
+
+// Wipe overwrites the password with zeroes, it can't be used afterwards.
+func (p *Password) Wipe() {
+	if p == nil {
+		return
+	}
+	wipeBytes(p.b)
+	p.b = nil
+	p.wiped = true
+}
+
+func (p Password) String() string {
+	return redacted
+}
+
+func (p Password) GoString() string {
+	return "gophc.Password{" + redacted + "}"
+}
+
+// Format implements fmt.Formatter, all verbs print the redacted value.
+func (p Password) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = io.WriteString(f, p.GoString())
+		return
+	}
+	_, _ = io.WriteString(f, redacted)
+}
+
+// MarshalText implements encoding.TextMarshaler, it returns the redacted value (for example for json).
+func (p Password) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// VerifyPassword is like VerifyContext, but accepts a Password.
+func VerifyPassword(ctx context.Context, h PasswordHash, password *Password) (bool, error) {
+	b, err := password.Bytes()
+	if err != nil {
+		return false, err
+	}
+	return VerifyContext(ctx, h, b)
+}
+
+// NewHashPassword is like NewHashContext, but accepts a Password.
+func NewHashPassword(ctx context.Context, hasher PasswordHasher, password *Password) (PasswordHash, error) {
+	b, err := password.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return NewHashContext(ctx, hasher, b)
+}
+
+// VerifyPassword is like VerifyContext, but accepts a Password.
+func (registry *Registry) VerifyPassword(ctx context.Context, s string, password *Password) (bool, error) {
+	b, err := password.Bytes()
+	if err != nil {
+		return false, err
+	}
+	return registry.VerifyContext(ctx, s, b)
+}
+
+// VerifyPassword is like Verify, but accepts a Password.
+func (verifier *BoundedVerifier) VerifyPassword(ctx context.Context, s string, password *Password) (bool, error) {
+	b, err := password.Bytes()
+	if err != nil {
+		return false, err
+	}
+	return verifier.Verify(ctx, s, b)
+}
+
+// NewHashPassword is like NewHash, but accepts a Password.
+func (verifier *BoundedVerifier) NewHashPassword(ctx context.Context, hasher PasswordHasher, password *Password) (PasswordHash, error) {
+	b, err := password.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return verifier.NewHash(ctx, hasher, b)
+}
+
+// VerifyPassword is like VerifyContext, but accepts a Password.
+func (verifier *DummyVerifier) VerifyPassword(ctx context.Context, password *Password) (bool, error) {
+	b, err := password.Bytes()
+	if err != nil {
+		return false, err
+	}
+	return verifier.VerifyContext(ctx, b)
+}
+
+// VerifyPassword is like VerifyContext, but accepts a Password.
+func (pepper *Pepper) VerifyPassword(ctx context.Context, s string, password *Password) (bool, error) {
+	b, err := password.Bytes()
+	if err != nil {
+		return false, err
+	}
+	return pepper.VerifyContext(ctx, s, b)
+}
+
+// NewHashPassword is like NewHashContext, but accepts a Password.
+func (pepper *Pepper) NewHashPassword(ctx context.Context, template PasswordHasher, password *Password) (PasswordHash, error) {
+	b, err := password.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return pepper.NewHashContext(ctx, template, b)
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"strconv"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var PBKDF2Variants = []string{
+	"pbkdf2-sha1",
+	"pbkdf2-sha256",
+	"pbkdf2-sha512",
+}
+
+func pbkdf2HashFunc(variant string) func() hash.Hash {
+	switch variant {
+	case "pbkdf2-sha1":
+		return sha1.New
+	case "pbkdf2-sha256":
+		return sha256.New
+	case "pbkdf2-sha512":
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+// PBKDF2PHC describes a PBKDF2 hash with HMAC as the pseudo random function.
+//
+// The phc format is $pbkdf2-<digest>$i=<iterations>$<salt>$<hash>, the length of the derived key is the length
+// of the hash.
+type PBKDF2PHC struct {
+	Variant    string
+	Iterations int
+	Salt       []byte
+	SaltString string
+	Hash       []byte
+	HashString string
+}
+
+func (phc *PBKDF2PHC) ValidateParameters() error {
+	if pbkdf2HashFunc(phc.Variant) == nil {
+		return NewMismatchedFunctionNameError(phc.Variant, PBKDF2Variants...)
+	}
+	if phc.Iterations < 1 {
+		return wrapParameterValueErrorToPHCError("must be > 0", "i", nil)
+	}
+	return nil
+}
+
+var PBKDF2Schema = &PHCSchema{
+	FunctionNames: PBKDF2Variants,
+	ParameterDescriptions: []*PHCParameterDescription{
+		{
+			Name:          "i",
+			Default:       "",
+			Optional:      false,
+			ValidateValue: NoValueValidator,
+		},
+	},
+	Decoder: DefaultBase64,
+	Encoder: DefaultBase64,
+}
+
+func pbkdf2FromStringParams(variant string, iParam ParameterValuePair, salt, hash []byte, saltString, hashString string) (*PBKDF2PHC, error) {
+	if pbkdf2HashFunc(variant) == nil {
+		return nil, NewMismatchedFunctionNameError(variant, PBKDF2Variants...)
+	}
+	// limit to 31 bit, this way it always fits into int
+	iterations, iterationsErr := decodeNoneZeroUnsignedString(iParam.Value, false, 31)
+	if iterationsErr != nil {
+		return nil, wrapParameterValueErrorToPHCError("can't parse as integer", iParam.Name, iterationsErr)
+	}
+	res := &PBKDF2PHC{
+		Variant:    variant,
+		Iterations: int(iterations),
+		Salt:       salt,
+		SaltString: saltString,
+		Hash:       hash,
+		HashString: hashString,
+	}
+	return res, nil
+}
+
+func DecodePBKDF2(phcString string) (*PBKDF2PHC, error) {
+	return decodePBKDF2(phcString, DefaultLimits)
+}
+
+func decodePBKDF2(phcString string, limits *Limits) (*PBKDF2PHC, error) {
+	if limitErr := limits.CheckString(phcString); limitErr != nil {
+		return nil, limitErr
+	}
+	instance, err := PBKDF2Schema.Decode(phcString)
+	if err != nil {
+		return nil, err
+	}
+	// just an assertion, should never happen
+	if len(instance.Parameters) != 1 {
+		return nil, fmt.Errorf("internal error: expected exactly 1 parameter, got %d instead", len(instance.Parameters))
+	}
+	res, paramsErr := pbkdf2FromStringParams(instance.Function, instance.Parameters[0], instance.Salt, instance.Hash,
+		instance.SaltString, instance.HashString)
+	if paramsErr != nil {
+		return nil, paramsErr
+	}
+	if limitErr := limits.CheckPBKDF2(res); limitErr != nil {
+		return nil, limitErr
+	}
+	return res, nil
+}
+
+// Encode returns the phc string of the instance.
+func (phc *PBKDF2PHC) Encode() (string, error) {
+	instance := &PHCInstance{
+		Function: phc.Variant,
+		Parameters: []ParameterValuePair{
+			{Name: "i", Value: strconv.Itoa(phc.Iterations), IsSet: true},
+		},
+		Salt: phc.Salt,
+		Hash: phc.Hash,
+	}
+	return PBKDF2Schema.Encode(instance)
+}
+
+// Verify checks if the password matches the hash.
+//
+// An error is returned if the parameters are invalid, the DefaultLimits are exceeded or no hash is given.
+func (phc *PBKDF2PHC) Verify(password []byte) (bool, error) {
+	return phc.verifyLimits(context.Background(), password, DefaultLimits)
+}
+
+func (phc *PBKDF2PHC) verifyLimits(_ context.Context, password []byte, limits *Limits) (bool, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return false, err
+	}
+	if limitErr := limits.CheckPBKDF2(phc); limitErr != nil {
+		return false, limitErr
+	}
+	if len(phc.Hash) == 0 {
+		return false, NewPHCError("can't verify password", ErrMissingHash)
+	}
+	computed := pbkdf2.Key(password, phc.Salt, phc.Iterations, len(phc.Hash), pbkdf2HashFunc(phc.Variant))
+	return equalAndWipe(computed, phc.Hash), nil
+}
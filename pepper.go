@@ -0,0 +1,282 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownPepper      = errors.New("unknown pepper id")
+	ErrPepperNotSupported = errors.New("hash doesn't support a pepper")
+)
+
+// MaxPepperIDLength is the maximum length of the pid parameter, the same limit as for the argon2 keyid.
+const MaxPepperIDLength = Argon2MaxKeyIDLength
+
+func validatePepperID(id []byte) error {
+	if len(id) > MaxPepperIDLength {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("must have at most %d bytes, got %d", MaxPepperIDLength, len(id)),
+			"pid", nil)
+	}
+	return nil
+}
+
+// decodePepperID decodes the base64 value of the pid parameter, nil is returned if it is not set.
+func decodePepperID(schema *PHCSchema, param ParameterValuePair) ([]byte, error) {
+	if !param.IsSet {
+		return nil, nil
+	}
+	id, err := schema.decodeBase64(param.Value)
+	if err != nil {
+		return nil, wrapParameterValueErrorToPHCError("can't decode base64", param.Name, err)
+	}
+	return id, nil
+}
+
+// KeyRing contains secret keys identified by an id, it is used for peppers (see Pepper) and the data keys of
+// encrypted envelopes (see EncryptEnvelope).
+type KeyRing interface {
+	// Key returns the key with the given id
+	Key(id []byte) ([]byte, bool)
+	// Current returns the id and key used for new hashes
+	Current() (id, key []byte)
+}
+
+// StaticKeyRing is a KeyRing with a fixed set of keys.
+type StaticKeyRing struct {
+	currentID []byte
+	keys      map[string][]byte
+}
+
+// NewStaticKeyRing returns a key ring with the given keys, the map is indexed by the ids.
+// The ids must not be empty and must have at most MaxPepperIDLength bytes, currentID must be one of the ids.
+// The keys must not be empty, keys used for envelopes must have 16, 24 or 32 bytes (see EncryptEnvelope).
+func NewStaticKeyRing(currentID []byte, keys map[string][]byte) (*StaticKeyRing, error) {
+	copied := make(map[string][]byte, len(keys))
+	for id, key := range keys {
+		if len(id) == 0 || len(id) > MaxPepperIDLength {
+			return nil, fmt.Errorf("key id must have between 1 and %d bytes, got %d", MaxPepperIDLength, len(id))
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("key id %x: key must not be empty", id)
+		}
+		copied[id] = key
+	}
+	if _, has := copied[string(currentID)]; !has {
+		return nil, fmt.Errorf("current key id %x is not in the key ring: %w", currentID, ErrUnknownPepper)
+	}
+	return &StaticKeyRing{
+		currentID: currentID,
+		keys:      copied,
+	}, nil
+}
+
+func (ring *StaticKeyRing) Key(id []byte) ([]byte, bool) {
+	key, has := ring.keys[string(id)]
+	return key, has
+}
+
+func (ring *StaticKeyRing) Current() (id, key []byte) {
+	return ring.currentID, ring.keys[string(ring.currentID)]
+}
+
+// PepperPassword returns HMAC-SHA256(key, password), this value is hashed instead of the password.
+func PepperPassword(key, password []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(password)
+	return mac.Sum(nil)
+}
+
+// PepperKeyID returns the id of the pepper recorded in h, it is stored in the pid parameter.
+// LDAP, Spring and envelope hashes are unwrapped. ok is false if h doesn't support a pid.
+func PepperKeyID(h PasswordHash) (id []byte, ok bool) {
+	switch v := h.(type) {
+	case *Argon2PHC:
+		return v.PepperID, true
+	case *ScryptPHC:
+		return v.PepperID, true
+	case *LDAPPassword:
+		return PepperKeyID(v.Hash)
+	case *SpringPasswordHash:
+		return PepperKeyID(v.Hash)
+	case *EnvelopeHash:
+		return PepperKeyID(v.Hash)
+	default:
+		return nil, false
+	}
+}
+
+// withKeyID returns a copy of the template with the pid set.
+func withKeyID(template PasswordHasher, id []byte) (PasswordHasher, error) {
+	switch v := template.(type) {
+	case *Argon2PHC:
+		res := v.template()
+		res.PepperID = id
+		res.Salt, res.Hash = v.Salt, v.Hash
+		return res, nil
+	case *ScryptPHC:
+		res := v.template()
+		res.PepperID = id
+		res.Salt, res.Hash = v.Salt, v.Hash
+		return res, nil
+	case *Preset:
+		if v.Argon2 != nil {
+			return withKeyID(v.Argon2, id)
+		}
+		return withKeyID(v.Scrypt, id)
+	default:
+		return nil, fmt.Errorf("can't use pepper with %T: %w", template, ErrPepperNotSupported)
+	}
+}
+
+// Pepper hashes passwords with a server-side secret (pepper): The password is replaced by
+// HMAC-SHA256(pepper, password) before it is hashed, the id of the pepper is stored in the pid parameter of
+// the argon2 or scrypt phc string. The argon2 keyid parameter is left to the secret key of HashPasswordWithSecret.
+//
+// Hashes without a pid are verified without a pepper, this way existing hashes remain valid. To rotate the
+// pepper add a new key to the KeyRing and make it the current one, NeedsRehash reports hashes that should be
+// computed again with the current pepper once the password is known (after a successful login).
+type Pepper struct {
+	KeyRing KeyRing
+	// Registry is used to decode hashes, if nil DefaultRegistry is used
+	Registry *Registry
+}
+
+func NewPepper(ring KeyRing, registry *Registry) *Pepper {
+	return &Pepper{
+		KeyRing:  ring,
+		Registry: registry,
+	}
+}
+
+func (pepper *Pepper) registry() *Registry {
+	if pepper.Registry == nil {
+		return DefaultRegistry
+	}
+	return pepper.Registry
+}
+
+// NewHash computes a new hash with the template and the current pepper.
+// The template must be an *Argon2PHC, *ScryptPHC or *Preset.
+func (pepper *Pepper) NewHash(template PasswordHasher, password []byte) (PasswordHash, error) {
+	return pepper.NewHashContext(context.Background(), template, password)
+}
+
+// NewHashContext is like NewHash, but stops early if ctx is done, see NewHashContext.
+func (pepper *Pepper) NewHashContext(ctx context.Context, template PasswordHasher, password []byte) (PasswordHash, error) {
+	id, key := pepper.KeyRing.Current()
+	hasher, err := withKeyID(template, id)
+	if err != nil {
+		return nil, err
+	}
+	peppered, pepperErr := pepperNormalized(key, PasswordNormalization(hasher.(PasswordHash)), password)
+	if pepperErr != nil {
+		return nil, pepperErr
+	}
+	defer wipeBytes(peppered)
+	return hashPeppered(ctx, hasher, peppered)
+}
+
+// Verify decodes s with the registry and verifies the password.
+func (pepper *Pepper) Verify(s string, password []byte) (bool, error) {
+	return pepper.VerifyContext(context.Background(), s, password)
+}
+
+// VerifyContext is like Verify, but stops early if ctx is done, see VerifyContext.
+func (pepper *Pepper) VerifyContext(ctx context.Context, s string, password []byte) (bool, error) {
+	h, err := pepper.registry().Decode(s)
+	if err != nil {
+		return false, err
+	}
+	return pepper.VerifyHash(ctx, h, password)
+}
+
+// VerifyHash verifies the password against an already decoded hash.
+//
+// If h has a pid the pepper is looked up in the KeyRing (ErrUnknownPepper is returned if it doesn't exist),
+// otherwise h is verified without a pepper.
+func (pepper *Pepper) VerifyHash(ctx context.Context, h PasswordHash, password []byte) (bool, error) {
+	id, _ := PepperKeyID(h)
+	if len(id) == 0 {
+		return verifyWithLimits(ctx, h, password, pepper.registry().limits())
+	}
+	key, has := pepper.KeyRing.Key(id)
+	if !has {
+		return false, fmt.Errorf("key id %x: %w", id, ErrUnknownPepper)
+	}
+	peppered, pepperErr := pepperNormalized(key, PasswordNormalization(h), password)
+	if pepperErr != nil {
+		return false, pepperErr
+	}
+	defer wipeBytes(peppered)
+	return verifyPeppered(ctx, h, peppered, pepper.registry().limits())
+}
+
+// pepperNormalized normalizes the password before the pepper is applied, the hash must not normalize the
+// peppered (binary) password again.
+func pepperNormalized(key []byte, profile string, password []byte) ([]byte, error) {
+	normalized, err := NormalizePassword(profile, password)
+	if err != nil {
+		return nil, err
+	}
+	if profile != NormalizationNone {
+		defer wipeBytes(normalized)
+	}
+	return PepperPassword(key, normalized), nil
+}
+
+// hashPeppered computes a new hash of the peppered password, it must not be normalized again.
+func hashPeppered(ctx context.Context, hasher PasswordHasher, peppered []byte) (PasswordHash, error) {
+	switch v := hasher.(type) {
+	case *Argon2PHC:
+		return v.hashContext(ctx, peppered, nil, DefaultLimits, false)
+	case *ScryptPHC:
+		return v.hashContext(ctx, peppered, DefaultLimits, false)
+	default:
+		return nil, fmt.Errorf("can't use pepper with %T: %w", hasher, ErrPepperNotSupported)
+	}
+}
+
+// verifyPeppered verifies the peppered password, it must not be normalized again.
+// LDAP, Spring and envelope hashes are unwrapped.
+func verifyPeppered(ctx context.Context, h PasswordHash, peppered []byte, limits *Limits) (bool, error) {
+	switch v := h.(type) {
+	case *Argon2PHC:
+		return v.verifyContext(ctx, peppered, nil, limits, false)
+	case *ScryptPHC:
+		return v.verify(ctx, peppered, limits, false)
+	case *LDAPPassword:
+		return verifyPeppered(ctx, v.Hash, peppered, limits)
+	case *SpringPasswordHash:
+		return verifyPeppered(ctx, v.Hash, peppered, limits)
+	case *EnvelopeHash:
+		return verifyPeppered(ctx, v.Hash, peppered, limits)
+	default:
+		return false, fmt.Errorf("can't use pepper with %T: %w", h, ErrPepperNotSupported)
+	}
+}
+
+// NeedsRehash returns true if h was not computed with the current pepper.
+func (pepper *Pepper) NeedsRehash(h PasswordHash) bool {
+	id, _ := PepperKeyID(h)
+	currentID, _ := pepper.KeyRing.Current()
+	return !bytes.Equal(id, currentID)
+}
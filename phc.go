@@ -51,6 +51,9 @@ type ParameterValuePair struct {
 type PHCInstance struct {
 	Function   string
 	Parameters []ParameterValuePair
+	// Extra contains the parameters not described by the schema (in the order of the string), see
+	// PreserveUnknownParameters. Encode writes them after the described parameters.
+	Extra      []ParameterValuePair
 	Salt       []byte
 	SaltString string
 	Hash       []byte
@@ -88,6 +91,8 @@ var (
 	ErrInvalidParameterValue = errors.New("invalid parameter value")
 	ErrMissingParameterValue = errors.New("no value for parameter given")
 	ErrBase64Decode          = errors.New("error decoding base64")
+	ErrMissingHash           = errors.New("no hash given")
+	ErrMissingSalt           = errors.New("no salt given")
 )
 
 func formatIntInterval(min, max int) string {
@@ -290,6 +295,11 @@ func (parser *PHCParser) Parse(s string) (PHCInstance, error) {
 	// "If the function expects no parameter at all, or all parameters are optional and their value happens to match
 	// the default, then the complete list, including its starting $ sign, is omitted. Note that the = sign may appear
 	// within the complete string only as part of a list of parameters."
+	// the optional version segment "$v=<version>" is added as first parameter
+	if len(split) > 1 && isVersionSegment(split[0]) && strings.ContainsRune(split[1], '=') {
+		split[1] = split[0] + "," + split[1]
+		split = split[1:]
+	}
 	if strings.ContainsRune(split[0], '=') {
 		parameters, parametersErr := parser.parseParameters(split[0])
 		if parametersErr != nil {
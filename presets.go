@@ -0,0 +1,262 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBelowPolicyMinimum = errors.New("parameters are below the policy minimum")
+)
+
+// Preset is a named set of recommended parameters.
+//
+// Exactly one of Argon2 and Scrypt is set, it is a template without salt and hash. The presets of this package are
+// returned as copies, so changing them has no effect on other users.
+type Preset struct {
+	Name string
+	// Source is the document the parameters are taken from, including its version or date
+	Source      string
+	Description string
+	Argon2      *Argon2PHC
+	Scrypt      *ScryptPHC
+}
+
+var (
+	argon2RFC9106First = &Preset{
+		Name:        "rfc9106-first",
+		Source:      "RFC 9106 (September 2021), section 4",
+		Description: "first recommended option: argon2id with t=1, p=4 and 2 GiB of memory",
+		Argon2:      &Argon2PHC{Variant: "argon2id", Version: 0x13, M: 2 * 1024 * 1024, T: 1, P: 4},
+	}
+
+	argon2RFC9106Second = &Preset{
+		Name:        "rfc9106-second",
+		Source:      "RFC 9106 (September 2021), section 4",
+		Description: "second recommended option if much less memory is available: argon2id with t=3, p=4 and 64 MiB of memory",
+		Argon2:      &Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64 * 1024, T: 3, P: 4},
+	}
+
+	argon2OWASP = &Preset{
+		Name:        "owasp-argon2id",
+		Source:      "OWASP Password Storage Cheat Sheet (2023)",
+		Description: "minimum configuration: argon2id with t=2, p=1 and 19 MiB of memory",
+		Argon2:      &Argon2PHC{Variant: "argon2id", Version: 0x13, M: 19 * 1024, T: 2, P: 1},
+	}
+
+	argon2LibsodiumInteractive = &Preset{
+		Name:        "libsodium-interactive",
+		Source:      "libsodium 1.0.18, crypto_pwhash OPSLIMIT_INTERACTIVE / MEMLIMIT_INTERACTIVE",
+		Description: "argon2id with t=2, p=1 and 64 MiB of memory for online operations",
+		Argon2:      &Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64 * 1024, T: 2, P: 1},
+	}
+
+	argon2LibsodiumModerate = &Preset{
+		Name:        "libsodium-moderate",
+		Source:      "libsodium 1.0.18, crypto_pwhash OPSLIMIT_MODERATE / MEMLIMIT_MODERATE",
+		Description: "argon2id with t=3, p=1 and 256 MiB of memory",
+		Argon2:      &Argon2PHC{Variant: "argon2id", Version: 0x13, M: 256 * 1024, T: 3, P: 1},
+	}
+
+	argon2LibsodiumSensitive = &Preset{
+		Name:        "libsodium-sensitive",
+		Source:      "libsodium 1.0.18, crypto_pwhash OPSLIMIT_SENSITIVE / MEMLIMIT_SENSITIVE",
+		Description: "argon2id with t=4, p=1 and 1 GiB of memory for highly sensitive data",
+		Argon2:      &Argon2PHC{Variant: "argon2id", Version: 0x13, M: 1024 * 1024, T: 4, P: 1},
+	}
+
+	scryptOWASP = &Preset{
+		Name:        "owasp-scrypt",
+		Source:      "OWASP Password Storage Cheat Sheet (2023)",
+		Description: "minimum configuration: scrypt with N=2^17, r=8 and p=1 (128 MiB of memory)",
+		Scrypt:      &ScryptPHC{Cost: 1 << 17, BlockSize: 8, Parallelism: 1},
+	}
+
+	scryptLibsodiumInteractive = &Preset{
+		Name:        "libsodium-scrypt-interactive",
+		Source:      "libsodium 1.0.18, crypto_pwhash_scryptsalsa208sha256 OPSLIMIT_INTERACTIVE / MEMLIMIT_INTERACTIVE",
+		Description: "scrypt with N=2^14, r=8 and p=1 (16 MiB of memory) for online operations",
+		Scrypt:      &ScryptPHC{Cost: 1 << 14, BlockSize: 8, Parallelism: 1},
+	}
+
+	scryptLibsodiumSensitive = &Preset{
+		Name:        "libsodium-scrypt-sensitive",
+		Source:      "libsodium 1.0.18, crypto_pwhash_scryptsalsa208sha256 OPSLIMIT_SENSITIVE / MEMLIMIT_SENSITIVE",
+		Description: "scrypt with N=2^20, r=8 and p=1 (1 GiB of memory) for highly sensitive data",
+		Scrypt:      &ScryptPHC{Cost: 1 << 20, BlockSize: 8, Parallelism: 1},
+	}
+)
+
+// presets contains all presets defined in this package.
+var presets = []*Preset{
+	argon2RFC9106First,
+	argon2RFC9106Second,
+	argon2OWASP,
+	argon2LibsodiumInteractive,
+	argon2LibsodiumModerate,
+	argon2LibsodiumSensitive,
+	scryptOWASP,
+	scryptLibsodiumInteractive,
+	scryptLibsodiumSensitive,
+}
+
+// clone returns a copy of the preset and its template.
+func (preset *Preset) clone() *Preset {
+	res := *preset
+	if preset.Argon2 != nil {
+		argon2 := *preset.Argon2
+		res.Argon2 = &argon2
+	}
+	if preset.Scrypt != nil {
+		scrypt := *preset.Scrypt
+		res.Scrypt = &scrypt
+	}
+	return &res
+}
+
+// Argon2RFC9106First returns a copy of the first recommended option of RFC 9106.
+func Argon2RFC9106First() *Preset {
+	return argon2RFC9106First.clone()
+}
+
+// Argon2RFC9106Second returns a copy of the second recommended option of RFC 9106.
+func Argon2RFC9106Second() *Preset {
+	return argon2RFC9106Second.clone()
+}
+
+// Argon2OWASP returns a copy of the OWASP minimum configuration for argon2id.
+func Argon2OWASP() *Preset {
+	return argon2OWASP.clone()
+}
+
+// Argon2LibsodiumInteractive returns a copy of the libsodium interactive limits for argon2id.
+func Argon2LibsodiumInteractive() *Preset {
+	return argon2LibsodiumInteractive.clone()
+}
+
+// Argon2LibsodiumModerate returns a copy of the libsodium moderate limits for argon2id.
+func Argon2LibsodiumModerate() *Preset {
+	return argon2LibsodiumModerate.clone()
+}
+
+// Argon2LibsodiumSensitive returns a copy of the libsodium sensitive limits for argon2id.
+func Argon2LibsodiumSensitive() *Preset {
+	return argon2LibsodiumSensitive.clone()
+}
+
+// ScryptOWASP returns a copy of the OWASP minimum configuration for scrypt.
+func ScryptOWASP() *Preset {
+	return scryptOWASP.clone()
+}
+
+// ScryptLibsodiumInteractive returns a copy of the libsodium interactive limits for scrypt.
+func ScryptLibsodiumInteractive() *Preset {
+	return scryptLibsodiumInteractive.clone()
+}
+
+// ScryptLibsodiumSensitive returns a copy of the libsodium sensitive limits for scrypt.
+func ScryptLibsodiumSensitive() *Preset {
+	return scryptLibsodiumSensitive.clone()
+}
+
+// Presets returns copies of all presets defined in this package.
+func Presets() []*Preset {
+	res := make([]*Preset, len(presets))
+	for i, preset := range presets {
+		res[i] = preset.clone()
+	}
+	return res
+}
+
+// PresetByName returns a copy of the preset with the given name.
+func PresetByName(name string) (*Preset, bool) {
+	for _, preset := range presets {
+		if preset.Name == name {
+			return preset.clone(), true
+		}
+	}
+	return nil, false
+}
+
+// NewHash implements PasswordHasher, it computes a new hash with the parameters of the preset.
+func (preset *Preset) NewHash(password []byte) (PasswordHash, error) {
+	switch {
+	case preset.Argon2 != nil:
+		return preset.Argon2.NewHash(password)
+	case preset.Scrypt != nil:
+		return preset.Scrypt.NewHash(password)
+	default:
+		return nil, fmt.Errorf("preset %s has no parameters", preset.Name)
+	}
+}
+
+// NewHashContext implements ContextPasswordHasher, it computes a new hash with the parameters of the preset.
+func (preset *Preset) NewHashContext(ctx context.Context, password []byte) (PasswordHash, error) {
+	switch {
+	case preset.Argon2 != nil:
+		return preset.Argon2.NewHashContext(ctx, password)
+	case preset.Scrypt != nil:
+		return preset.Scrypt.NewHashContext(ctx, password)
+	default:
+		return nil, fmt.Errorf("preset %s has no parameters", preset.Name)
+	}
+}
+
+func newBelowPolicyMinimumError(preset *Preset, message string) error {
+	return fmt.Errorf("%s (preset %s): %w", message, preset.Name, ErrBelowPolicyMinimum)
+}
+
+// CheckMinimum uses the preset as a policy minimum: It returns an error wrapping ErrBelowPolicyMinimum if h is
+// not of the same algorithm or if a parameter is smaller than the corresponding parameter of the preset.
+//
+// For argon2 the variant must match and the version, m, t and p must be at least as large as in the preset, for
+// scrypt N, r and p must be at least as large.
+func (preset *Preset) CheckMinimum(h PasswordHash) error {
+	switch {
+	case preset.Argon2 != nil:
+		phc, ok := h.(*Argon2PHC)
+		if !ok {
+			return newBelowPolicyMinimumError(preset, fmt.Sprintf("expected argon2 hash, got %T", h))
+		}
+		minimum := preset.Argon2
+		if phc.Variant != minimum.Variant {
+			return newBelowPolicyMinimumError(preset, fmt.Sprintf("expected variant %s, got %s", minimum.Variant, phc.Variant))
+		}
+		if phc.Version < minimum.Version {
+			return newBelowPolicyMinimumError(preset, fmt.Sprintf("expected at least version %d, got %d", minimum.Version, phc.Version))
+		}
+		if phc.M < minimum.M || phc.T < minimum.T || phc.P < minimum.P {
+			return newBelowPolicyMinimumError(preset, fmt.Sprintf("expected at least m=%d,t=%d,p=%d, got m=%d,t=%d,p=%d",
+				minimum.M, minimum.T, minimum.P, phc.M, phc.T, phc.P))
+		}
+		return nil
+	case preset.Scrypt != nil:
+		phc, ok := h.(*ScryptPHC)
+		if !ok {
+			return newBelowPolicyMinimumError(preset, fmt.Sprintf("expected scrypt hash, got %T", h))
+		}
+		minimum := preset.Scrypt
+		if phc.Cost < minimum.Cost || phc.BlockSize < minimum.BlockSize || phc.Parallelism < minimum.Parallelism {
+			return newBelowPolicyMinimumError(preset, fmt.Sprintf("expected at least N=%d,r=%d,p=%d, got N=%d,r=%d,p=%d",
+				minimum.Cost, minimum.BlockSize, minimum.Parallelism, phc.Cost, phc.BlockSize, phc.Parallelism))
+		}
+		return nil
+	default:
+		return fmt.Errorf("preset %s has no parameters", preset.Name)
+	}
+}
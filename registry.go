@@ -0,0 +1,318 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PasswordHash is a decoded password hash that can be encoded again and used to verify passwords.
+type PasswordHash interface {
+	Encode() (string, error)
+	Verify(password []byte) (bool, error)
+}
+
+// PasswordHasher computes new hashes, usually it's a parameters only instance (a template) such as an
+// Argon2PHC without salt and hash.
+type PasswordHasher interface {
+	NewHash(password []byte) (PasswordHash, error)
+}
+
+// ContextPasswordHash is a PasswordHash whose verification can be cancelled.
+type ContextPasswordHash interface {
+	PasswordHash
+	VerifyContext(ctx context.Context, password []byte) (bool, error)
+}
+
+// ContextPasswordHasher is a PasswordHasher whose hash computation can be cancelled.
+type ContextPasswordHasher interface {
+	PasswordHasher
+	NewHashContext(ctx context.Context, password []byte) (PasswordHash, error)
+}
+
+// VerifyContext verifies the password with h.VerifyContext if h implements ContextPasswordHash.
+// Otherwise ctx is only checked before h.Verify is called.
+//
+// The argon2 and scrypt based hashes in this package implement ContextPasswordHash, all other hashes are fast.
+func VerifyContext(ctx context.Context, h PasswordHash, password []byte) (bool, error) {
+	if withContext, ok := h.(ContextPasswordHash); ok {
+		return withContext.VerifyContext(ctx, password)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return h.Verify(password)
+}
+
+// VerifyContext is like Verify, but stops early if ctx is done, see VerifyContext.
+func (registry *Registry) VerifyContext(ctx context.Context, s string, password []byte) (bool, error) {
+	h, err := registry.Decode(s)
+	if err != nil {
+		return false, err
+	}
+	return verifyWithLimits(ctx, h, password, registry.limits())
+}
+
+// limitedVerifier is implemented by hashes that check resource limits before the hash is computed.
+// Verify and VerifyContext of these hashes check the DefaultLimits, a Registry passes its own limits instead.
+type limitedVerifier interface {
+	verifyLimits(ctx context.Context, password []byte, limits *Limits) (bool, error)
+}
+
+// verifyWithLimits is like VerifyContext, but enforces limits instead of DefaultLimits if h implements
+// limitedVerifier.
+func verifyWithLimits(ctx context.Context, h PasswordHash, password []byte, limits *Limits) (bool, error) {
+	limited, ok := h.(limitedVerifier)
+	if !ok {
+		return VerifyContext(ctx, h, password)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return limited.verifyLimits(ctx, password, limits)
+}
+
+// NewHashContext computes a new hash with hasher.NewHashContext if hasher implements ContextPasswordHasher.
+// Otherwise ctx is only checked before hasher.NewHash is called.
+func NewHashContext(ctx context.Context, hasher PasswordHasher, password []byte) (PasswordHash, error) {
+	if withContext, ok := hasher.(ContextPasswordHasher); ok {
+		return withContext.NewHashContext(ctx, password)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return hasher.NewHash(password)
+}
+
+// DecoderFunc decodes a string into a PasswordHash.
+type DecoderFunc func(s string) (PasswordHash, error)
+
+// limitedDecoderFunc decodes a string into a PasswordHash and enforces the limits (no limits if nil).
+type limitedDecoderFunc func(s string, limits *Limits) (PasswordHash, error)
+
+// registryDecoder is either a DecoderFunc added with Register or one of the decoders of this package, the latter
+// enforce the limits of the registry themselves.
+type registryDecoder struct {
+	decoder DecoderFunc
+	limited limitedDecoderFunc
+}
+
+func (entry registryDecoder) decode(s string, limits *Limits) (PasswordHash, error) {
+	if entry.limited != nil {
+		return entry.limited(s, limits)
+	}
+	h, err := entry.decoder(s)
+	if err != nil {
+		return nil, err
+	}
+	if limitErr := limits.Check(h); limitErr != nil {
+		return nil, limitErr
+	}
+	return h, nil
+}
+
+var (
+	ErrUnknownFunction = errors.New("no decoder registered for function")
+)
+
+// Registry maps function names to decoders.
+//
+// The function name of a string is the part between the first and the second '$', for example "argon2id" for
+// phc strings or "2b" for bcrypt. A Registry is safe for concurrent use.
+type Registry struct {
+	// Limits are enforced when hashes are decoded and verified with the registry, if nil DefaultLimits is used.
+	// It must not be changed once the registry is in use.
+	Limits *Limits
+	// ParameterOptions are used to decode argon2 and scrypt strings, by default the parameters must be in the order
+	// of the schema and unknown parameters are rejected. It must not be changed once the registry is in use.
+	ParameterOptions ParameterOptions
+	mutex            sync.RWMutex
+	decoders         map[string]registryDecoder
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		decoders: make(map[string]registryDecoder),
+	}
+}
+
+// NewDefaultRegistry returns a registry with all hashes implemented in this package.
+func NewDefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.registerLimited(registry.decodeArgon2Hash, Argon2Variants...)
+	registry.registerLimited(registry.decodeScryptHash, ScryptPHCSchema.FunctionNames...)
+	registry.registerLimited(decodeFirebaseScryptHash, FirebaseScryptSchema.FunctionNames...)
+	registry.registerLimited(decodePBKDF2Hash, PBKDF2Variants...)
+	registry.registerLimited(decodeOnionHash, OnionFunctionNames()...)
+	registry.registerLimited(decodeBcryptHash, BcryptVariants...)
+	registry.registerLimited(decodeSHACryptHash, SHACryptVariants...)
+	return registry
+}
+
+var DefaultRegistry = NewDefaultRegistry()
+
+// limits returns the limits of the registry, DefaultLimits if Limits is nil.
+func (registry *Registry) limits() *Limits {
+	if registry.Limits == nil {
+		return DefaultLimits
+	}
+	return registry.Limits
+}
+
+// Register registers the decoder for all given function names, existing decoders are replaced.
+//
+// The limits of the registry are checked after the decoder returns, see Limits.Check.
+func (registry *Registry) Register(decoder DecoderFunc, functionNames ...string) {
+	registry.register(registryDecoder{decoder: decoder}, functionNames)
+}
+
+func (registry *Registry) registerLimited(decoder limitedDecoderFunc, functionNames ...string) {
+	registry.register(registryDecoder{limited: decoder}, functionNames)
+}
+
+func (registry *Registry) register(entry registryDecoder, functionNames []string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	for _, name := range functionNames {
+		registry.decoders[name] = entry
+	}
+}
+
+func (registry *Registry) lookup(functionName string) (registryDecoder, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	entry, has := registry.decoders[functionName]
+	return entry, has
+}
+
+// Decoder returns the decoder registered for the function name, it enforces the limits of the registry.
+func (registry *Registry) Decoder(functionName string) (DecoderFunc, bool) {
+	entry, has := registry.lookup(functionName)
+	if !has {
+		return nil, false
+	}
+	return func(s string) (PasswordHash, error) {
+		return entry.decode(s, registry.limits())
+	}, true
+}
+
+// FunctionNames returns all registered function names in sorted order.
+func (registry *Registry) FunctionNames() []string {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	res := make([]string, 0, len(registry.decoders))
+	for name := range registry.decoders {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// FunctionName returns the function name of s, that is the part between the first and the second '$'.
+func FunctionName(s string) (string, error) {
+	if !strings.HasPrefix(s, "$") {
+		return "", newInvalidPHCStructureError("phc string must begin with \"$\"")
+	}
+	s = s[1:]
+	if index := strings.IndexRune(s, '$'); index >= 0 {
+		s = s[:index]
+	}
+	return s, nil
+}
+
+// Decode decodes s with the decoder registered for its function name.
+// The string limits of the registry are checked before the decoder is called, all other limits after decoding.
+func (registry *Registry) Decode(s string) (PasswordHash, error) {
+	limits := registry.limits()
+	if limitErr := limits.CheckString(s); limitErr != nil {
+		return nil, limitErr
+	}
+	functionName, nameErr := FunctionName(s)
+	if nameErr != nil {
+		return nil, nameErr
+	}
+	entry, has := registry.lookup(functionName)
+	if !has {
+		return nil, NewPHCError(fmt.Sprintf("function \"%s\"", functionName), ErrUnknownFunction)
+	}
+	return entry.decode(s, limits)
+}
+
+// Verify decodes s and verifies the password against it, the limits of the registry are checked again before the
+// hash is computed.
+func (registry *Registry) Verify(s string, password []byte) (bool, error) {
+	return registry.VerifyContext(context.Background(), s, password)
+}
+
+func (registry *Registry) decodeArgon2Hash(s string, limits *Limits) (PasswordHash, error) {
+	res, err := decodeArgon2(s, limits, registry.ParameterOptions)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (registry *Registry) decodeScryptHash(s string, limits *Limits) (PasswordHash, error) {
+	res, err := decodeScrypt(s, limits, registry.ParameterOptions)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func decodeFirebaseScryptHash(s string, limits *Limits) (PasswordHash, error) {
+	res, err := decodeFirebaseScrypt(s, limits)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func decodePBKDF2Hash(s string, limits *Limits) (PasswordHash, error) {
+	res, err := decodePBKDF2(s, limits)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func decodeOnionHash(s string, limits *Limits) (PasswordHash, error) {
+	res, err := decodeOnion(s, limits)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func decodeBcryptHash(s string, limits *Limits) (PasswordHash, error) {
+	res, err := decodeBcrypt(s, limits)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func decodeSHACryptHash(s string, limits *Limits) (PasswordHash, error) {
+	res, err := decodeSHACrypt(s, limits)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
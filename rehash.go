@@ -0,0 +1,50 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+// NeedsRehash returns true if h should be computed again with template once the password is known (after a
+// successful login).
+//
+// This is the case if h uses another algorithm or other parameters than template. Onion hashes always need a
+// rehash, this way they're unwrapped to a plain argon2 hash. LDAP, Spring and envelope hashes are unwrapped and
+// template can be an *Argon2PHC, *ScryptPHC or *Preset. Salt, hash, keyid and pid are not compared, see
+// Pepper.NeedsRehash for the pid.
+func NeedsRehash(h PasswordHash, template PasswordHasher) bool {
+	switch v := h.(type) {
+	case *LDAPPassword:
+		return NeedsRehash(v.Hash, template)
+	case *SpringPasswordHash:
+		return NeedsRehash(v.Hash, template)
+	case *EnvelopeHash:
+		return NeedsRehash(v.Hash, template)
+	}
+	switch t := template.(type) {
+	case *Preset:
+		if t.Argon2 != nil {
+			return NeedsRehash(h, t.Argon2)
+		}
+		return NeedsRehash(h, t.Scrypt)
+	case *Argon2PHC:
+		phc, ok := h.(*Argon2PHC)
+		return !ok || phc.Variant != t.Variant || phc.Version != t.Version || phc.M != t.M || phc.T != t.T ||
+			phc.P != t.P || string(phc.Data) != string(t.Data) || phc.Normalization != t.Normalization
+	case *ScryptPHC:
+		phc, ok := h.(*ScryptPHC)
+		return !ok || phc.Cost != t.Cost || phc.BlockSize != t.BlockSize || phc.Parallelism != t.Parallelism ||
+			phc.Normalization != t.Normalization
+	default:
+		return true
+	}
+}
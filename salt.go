@@ -0,0 +1,97 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	ErrSaltGeneration = errors.New("can't generate salt")
+)
+
+// SaltGenerator generates random salts.
+//
+// The salt length for a function name (for example "argon2id") is set with SetLength, this is safe while salts are
+// generated. Rand and DefaultLength must not be changed once the generator is in use.
+type SaltGenerator struct {
+	// Rand is the source of randomness, if nil crypto/rand.Reader is used
+	Rand io.Reader
+	// DefaultLength is used for all functions without a length set with SetLength
+	DefaultLength int
+	mutex         sync.RWMutex
+	lengths       map[string]int
+}
+
+// NewSaltGenerator returns a generator reading from r (crypto/rand.Reader if r is nil).
+// All salts have a length of DefaultSaltLength.
+func NewSaltGenerator(r io.Reader) *SaltGenerator {
+	return &SaltGenerator{
+		Rand:          r,
+		DefaultLength: DefaultSaltLength,
+		lengths:       make(map[string]int),
+	}
+}
+
+// DefaultSaltGenerator is used by all functions that compute new hashes, it uses crypto/rand.
+// It can be replaced, for example with a deterministic reader in tests.
+var DefaultSaltGenerator = NewSaltGenerator(nil)
+
+// SetLength sets the salt length in bytes for the function.
+func (generator *SaltGenerator) SetLength(function string, length int) {
+	generator.mutex.Lock()
+	defer generator.mutex.Unlock()
+	if generator.lengths == nil {
+		generator.lengths = make(map[string]int)
+	}
+	generator.lengths[function] = length
+}
+
+// Length returns the salt length for the function.
+func (generator *SaltGenerator) Length(function string) int {
+	generator.mutex.RLock()
+	defer generator.mutex.RUnlock()
+	if length, has := generator.lengths[function]; has {
+		return length
+	}
+	return generator.DefaultLength
+}
+
+// Generate returns a new salt for the function.
+func (generator *SaltGenerator) Generate(function string) ([]byte, error) {
+	return generator.GenerateN(generator.Length(function))
+}
+
+// GenerateN returns a new salt of length n.
+//
+// If the source of randomness fails or returns fewer than n bytes an error wrapping ErrSaltGeneration is returned.
+func (generator *SaltGenerator) GenerateN(n int) ([]byte, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("salt length must be > 0, got %d: %w", n, ErrSaltGeneration)
+	}
+	r := generator.Rand
+	if r == nil {
+		r = rand.Reader
+	}
+	salt := make([]byte, n)
+	if read, err := io.ReadFull(r, salt); err != nil {
+		return nil, fmt.Errorf("read %d of %d bytes: %v: %w", read, n, err, ErrSaltGeneration)
+	}
+	return salt, nil
+}
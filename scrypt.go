@@ -15,8 +15,10 @@
 package gophc
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"math/bits"
 	"strconv"
 )
 
@@ -27,10 +29,17 @@ type ScryptPHC struct {
 	BlockSize int
 	// The parallelism parameter p
 	Parallelism int
-	Salt        []byte
-	SaltString  string
-	Hash        []byte
-	HashString  string
+	// PepperID is the optional id of the pepper used to compute the hash, see Pepper
+	PepperID []byte
+	// Normalization is the profile applied to the password before it is hashed, see NormalizePassword
+	Normalization string
+	Salt          []byte
+	SaltString    string
+	Hash          []byte
+	HashString    string
+	// Extra contains the parameters not described by the schema, see PreserveUnknownParameters and ParameterOptions.
+	// They're written by Encode, but not used to compute the hash and not copied to new hashes.
+	Extra []ParameterValuePair
 }
 
 func (phc *ScryptPHC) ValidateParameters() error {
@@ -53,8 +62,11 @@ func (phc *ScryptPHC) ValidateParameters() error {
 		return wrapMultipleParametersValueErrorToPHCError("parameters are too large", nil,
 			"N", "p", "r")
 	}
+	if err := validatePepperID(phc.PepperID); err != nil {
+		return err
+	}
 
-	return nil
+	return validateNormalization(phc.Normalization)
 }
 
 var ScryptPHCSchema = &PHCSchema{
@@ -78,11 +90,24 @@ var ScryptPHCSchema = &PHCSchema{
 			Optional:      false,
 			ValidateValue: NoValueValidator,
 		},
+		{
+			Name:          "pid",
+			Default:       "",
+			Optional:      true,
+			ValidateValue: ValueCharacterValidator,
+		},
+		{
+			Name:          "norm",
+			Default:       "",
+			Optional:      true,
+			ValidateValue: ValueCharacterValidator,
+		},
 	},
 	Decoder: DefaultBase64,
+	Encoder: DefaultBase64,
 }
 
-func scryptFromStringParams(lnParam, rParam, pParam ParameterValuePair, salt, hash []byte, saltString, hashString string) (*ScryptPHC, error) {
+func scryptFromStringParams(lnParam, rParam, pParam, pidParam ParameterValuePair, salt, hash []byte, saltString, hashString string) (*ScryptPHC, error) {
 	ln, lnErr := strconv.Atoi(lnParam.Value)
 	if lnErr != nil {
 		return nil, wrapParameterValueErrorToPHCError("can't parse as integer", lnParam.Name, lnErr)
@@ -111,10 +136,16 @@ func scryptFromStringParams(lnParam, rParam, pParam ParameterValuePair, salt, ha
 		return nil, wrapParameterValueErrorToPHCError("can't parse as integer", pParam.Name, pErr)
 	}
 
+	pepperID, pidErr := decodePepperID(ScryptPHCSchema, pidParam)
+	if pidErr != nil {
+		return nil, pidErr
+	}
+
 	res := &ScryptPHC{
 		Cost:        cost,
 		BlockSize:   r,
 		Parallelism: p,
+		PepperID:    pepperID,
 		Salt:        salt,
 		SaltString:  saltString,
 		Hash:        hash,
@@ -124,17 +155,182 @@ func scryptFromStringParams(lnParam, rParam, pParam ParameterValuePair, salt, ha
 	return res, nil
 }
 
+// DecodeScrypt decodes a scrypt phc string, the DefaultLimits are enforced.
 func DecodeScrypt(phcString string) (*ScryptPHC, error) {
-	instance, err := ScryptPHCSchema.Decode(phcString)
+	return decodeScrypt(phcString, DefaultLimits, ParameterOptions{})
+}
+
+// DecodeScryptWithOptions is like DecodeScrypt, but matches the parameters according to options.
+// For example ParameterOptions{UnorderedParameters: true} accepts "$scrypt$p=1,ln=16,r=8$...".
+func DecodeScryptWithOptions(phcString string, options ParameterOptions) (*ScryptPHC, error) {
+	return decodeScrypt(phcString, DefaultLimits, options)
+}
+
+func decodeScrypt(phcString string, limits *Limits, options ParameterOptions) (*ScryptPHC, error) {
+	if limitErr := limits.CheckString(phcString); limitErr != nil {
+		return nil, limitErr
+	}
+	instance, err := ScryptPHCSchema.withOptions(options).Decode(phcString)
 	if err != nil {
 		return nil, err
 	}
 	// just an assertion, should never happen
-	if len(instance.Parameters) != 3 {
-		return nil, fmt.Errorf("internal error: expected exactly 3 parameters, got %d instead", len(instance.Parameters))
+	if len(instance.Parameters) != 5 {
+		return nil, fmt.Errorf("internal error: expected exactly 5 parameters, got %d instead", len(instance.Parameters))
 	}
 	lnParam := instance.Parameters[0]
 	rParam := instance.Parameters[1]
 	pParam := instance.Parameters[2]
-	return scryptFromStringParams(lnParam, rParam, pParam, instance.Salt, instance.Hash, instance.SaltString, instance.HashString)
+	pidParam := instance.Parameters[3]
+	normParam := instance.Parameters[4]
+	res, paramsErr := scryptFromStringParams(lnParam, rParam, pParam, pidParam, instance.Salt, instance.Hash, instance.SaltString, instance.HashString)
+	if paramsErr != nil {
+		return nil, paramsErr
+	}
+	res.Normalization = normParam.Value
+	res.Extra = instance.Extra
+	if normErr := validateNormalization(res.Normalization); normErr != nil {
+		return nil, normErr
+	}
+	if limitErr := limits.CheckScrypt(res); limitErr != nil {
+		return nil, limitErr
+	}
+	return res, nil
+}
+
+// Encode returns the phc string of the instance.
+func (phc *ScryptPHC) Encode() (string, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return "", err
+	}
+	// cost is a power of 2, so the logarithm is the number of trailing zeroes
+	ln := bits.TrailingZeros(uint(phc.Cost))
+	instance := &PHCInstance{
+		Function: "scrypt",
+		Parameters: []ParameterValuePair{
+			{Name: "ln", Value: strconv.Itoa(ln), IsSet: true},
+			{Name: "r", Value: strconv.Itoa(phc.BlockSize), IsSet: true},
+			{Name: "p", Value: strconv.Itoa(phc.Parallelism), IsSet: true},
+			{Name: "pid", Value: ScryptPHCSchema.encodeBase64(phc.PepperID), IsSet: len(phc.PepperID) > 0},
+			{Name: "norm", Value: phc.Normalization, IsSet: phc.Normalization != NormalizationNone},
+		},
+		Extra: phc.Extra,
+		Salt:  phc.Salt,
+		Hash:  phc.Hash,
+	}
+	return ScryptPHCSchema.Encode(instance)
+}
+
+// HashPassword computes a new hash of the password with the parameters of phc and a new random salt.
+// phc is used as a template and is not changed, the salt has the length of phc.Salt and the hash the length of
+// phc.Hash. If they're empty the salt length of DefaultSaltGenerator and DefaultHashLength are used.
+func (phc *ScryptPHC) HashPassword(password []byte) (*ScryptPHC, error) {
+	return phc.HashContext(context.Background(), password)
+}
+
+// HashContext is like HashPassword, but stops early if ctx is done, see ScryptKeyContext.
+func (phc *ScryptPHC) HashContext(ctx context.Context, password []byte) (*ScryptPHC, error) {
+	return phc.hashContext(ctx, password, DefaultLimits, true)
+}
+
+func (phc *ScryptPHC) hashLimits(ctx context.Context, password []byte, limits *Limits) (PasswordHash, error) {
+	return phc.hashContext(ctx, password, limits, true)
+}
+
+// hashContext computes a new hash, the password is normalized only if normalize is true.
+func (phc *ScryptPHC) hashContext(ctx context.Context, password []byte, limits *Limits, normalize bool) (*ScryptPHC, error) {
+	res := phc.template()
+	if err := res.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	if limitErr := limits.CheckScrypt(res); limitErr != nil {
+		return nil, limitErr
+	}
+	saltLength, hashLength := len(phc.Salt), len(phc.Hash)
+	if saltLength == 0 {
+		saltLength = DefaultSaltGenerator.Length("scrypt")
+	}
+	if hashLength == 0 {
+		hashLength = DefaultHashLength
+	}
+	salt, saltErr := DefaultSaltGenerator.GenerateN(saltLength)
+	if saltErr != nil {
+		return nil, saltErr
+	}
+	res.Salt = salt
+	hash, hashErr := res.computeHash(ctx, password, hashLength, normalize)
+	if hashErr != nil {
+		return nil, hashErr
+	}
+	res.Hash = hash
+	res.SaltString = ScryptPHCSchema.encodeBase64(res.Salt)
+	res.HashString = ScryptPHCSchema.encodeBase64(res.Hash)
+	return res, nil
+}
+
+// NewHash implements PasswordHasher, see HashPassword.
+func (phc *ScryptPHC) NewHash(password []byte) (PasswordHash, error) {
+	return phc.HashPassword(password)
+}
+
+// NewHashContext implements ContextPasswordHasher, see HashContext.
+func (phc *ScryptPHC) NewHashContext(ctx context.Context, password []byte) (PasswordHash, error) {
+	return phc.HashContext(ctx, password)
+}
+
+// template returns a copy of the parameters of phc without salt and hash.
+func (phc *ScryptPHC) template() *ScryptPHC {
+	return &ScryptPHC{
+		Cost:          phc.Cost,
+		BlockSize:     phc.BlockSize,
+		Parallelism:   phc.Parallelism,
+		PepperID:      phc.PepperID,
+		Normalization: phc.Normalization,
+	}
+}
+
+// computeHash normalizes the password (if normalize is true) and computes the key.
+func (phc *ScryptPHC) computeHash(ctx context.Context, password []byte, keyLen int, normalize bool) ([]byte, error) {
+	normalized, wipe, normErr := normalizeIf(normalize, phc.Normalization, password)
+	if normErr != nil {
+		return nil, normErr
+	}
+	if wipe {
+		defer wipeBytes(normalized)
+	}
+	return ScryptKeyContext(ctx, normalized, phc.Salt, phc.Cost, phc.BlockSize, phc.Parallelism, keyLen)
+}
+
+// Verify checks if the password matches the hash.
+//
+// An error is returned if the parameters are invalid, the DefaultLimits are exceeded or no hash is given.
+func (phc *ScryptPHC) Verify(password []byte) (bool, error) {
+	return phc.VerifyContext(context.Background(), password)
+}
+
+// VerifyContext is like Verify, but stops early if ctx is done, see ScryptKeyContext.
+func (phc *ScryptPHC) VerifyContext(ctx context.Context, password []byte) (bool, error) {
+	return phc.verifyLimits(ctx, password, DefaultLimits)
+}
+
+func (phc *ScryptPHC) verifyLimits(ctx context.Context, password []byte, limits *Limits) (bool, error) {
+	return phc.verify(ctx, password, limits, true)
+}
+
+// verify checks if the password matches the hash, the password is normalized only if normalize is true.
+func (phc *ScryptPHC) verify(ctx context.Context, password []byte, limits *Limits, normalize bool) (bool, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return false, err
+	}
+	if limitErr := limits.CheckScrypt(phc); limitErr != nil {
+		return false, limitErr
+	}
+	if len(phc.Hash) == 0 {
+		return false, NewPHCError("can't verify password", ErrMissingHash)
+	}
+	computed, err := phc.computeHash(ctx, password, len(phc.Hash), normalize)
+	if err != nil {
+		return false, err
+	}
+	return equalAndWipe(computed, phc.Hash), nil
 }
@@ -0,0 +1,183 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// A pure Go implementation of scrypt as described in RFC 7914, the structure follows the implementation in
+// golang.org/x/crypto/scrypt. In contrast to golang.org/x/crypto/scrypt the computation can be cancelled
+// with a context.
+
+// the context is checked every scryptCheckInterval iterations of each ROMix loop
+const scryptCheckInterval = 1024
+
+// ScryptKeyContext derives a key from the password and salt, see golang.org/x/crypto/scrypt.Key for a
+// description of the parameters.
+//
+// If ctx is done the computation stops and ctx.Err() is returned. The context is checked every 1024 iterations
+// of the two ROMix loops (of each of the p blocks).
+func ScryptKeyContext(ctx context.Context, password, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+	phc := &ScryptPHC{Cost: N, BlockSize: r, Parallelism: p}
+	if err := phc.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	xy := make([]uint32, 64*r)
+	v := make([]uint32, 32*N*r)
+	b := pbkdf2.Key(password, salt, 1, p*128*r, sha256.New)
+
+	for i := 0; i < p; i++ {
+		if err := scryptSMix(ctx, b[i*128*r:], r, N, v, xy); err != nil {
+			return nil, err
+		}
+	}
+
+	return pbkdf2.Key(password, b, 1, keyLen, sha256.New), nil
+}
+
+func scryptBlockCopy(dst, src []uint32, n int) {
+	copy(dst, src[:n])
+}
+
+func scryptBlockXOR(dst, src []uint32, n int) {
+	for i, v := range src[:n] {
+		dst[i] ^= v
+	}
+}
+
+// scryptSalsaXOR applies Salsa20/8 to the xor of in and tmp, the result is stored in tmp and out.
+func scryptSalsaXOR(tmp *[16]uint32, in, out []uint32) {
+	var x [16]uint32
+	for i := range x {
+		tmp[i] ^= in[i]
+		x[i] = tmp[i]
+	}
+
+	for i := 0; i < 8; i += 2 {
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+
+	for i := range x {
+		tmp[i] += x[i]
+		out[i] = tmp[i]
+	}
+}
+
+func scryptBlockMix(tmp *[16]uint32, in, out []uint32, r int) {
+	scryptBlockCopy(tmp[:], in[(2*r-1)*16:], 16)
+	for i := 0; i < 2*r; i += 2 {
+		scryptSalsaXOR(tmp, in[i*16:], out[i*8:])
+		scryptSalsaXOR(tmp, in[i*16+16:], out[i*8+r*16:])
+	}
+}
+
+func scryptInteger(b []uint32, r int) uint64 {
+	j := (2*r - 1) * 16
+	return uint64(b[j]) | uint64(b[j+1])<<32
+}
+
+// scryptSMix is the ROMix function of RFC 7914, b is changed in place.
+func scryptSMix(ctx context.Context, b []byte, r, N int, v, xy []uint32) error {
+	var tmp [16]uint32
+	R := 32 * r
+	x := xy
+	y := xy[R:]
+
+	j := 0
+	for i := 0; i < R; i++ {
+		x[i] = binary.LittleEndian.Uint32(b[j:])
+		j += 4
+	}
+	for i := 0; i < N; i += 2 {
+		if i%scryptCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		scryptBlockCopy(v[i*R:], x, R)
+		scryptBlockMix(&tmp, x, y, r)
+
+		scryptBlockCopy(v[(i+1)*R:], y, R)
+		scryptBlockMix(&tmp, y, x, r)
+	}
+	for i := 0; i < N; i += 2 {
+		if i%scryptCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		j := int(scryptInteger(x, r) & uint64(N-1))
+		scryptBlockXOR(x, v[j*R:], R)
+		scryptBlockMix(&tmp, x, y, r)
+
+		j = int(scryptInteger(y, r) & uint64(N-1))
+		scryptBlockXOR(y, v[j*R:], R)
+		scryptBlockMix(&tmp, y, x, r)
+	}
+	j = 0
+	for _, v := range x[:R] {
+		binary.LittleEndian.PutUint32(b[j:], v)
+		j += 4
+	}
+	return nil
+}
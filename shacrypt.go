@@ -0,0 +1,316 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SHA-crypt as specified in https://www.akkadia.org/drepper/SHA-crypt.txt
+// The format is $5$[rounds=<rounds>$]<salt>$<hash> for SHA-256 and $6$... for SHA-512.
+
+var SHACryptVariants = []string{
+	"5",
+	"6",
+}
+
+const (
+	shaCryptDefaultRounds = 5000
+	shaCryptMinRounds     = 1000
+	shaCryptMaxRounds     = 999999999
+	shaCryptMaxSaltLength = 16
+	shaCryptRoundsPrefix  = "rounds="
+	cryptAlphabet         = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+var (
+	ErrInvalidSHACryptHash = errors.New("invalid sha-crypt hash")
+)
+
+// the order in which the bytes of the digest are encoded, each entry encodes three bytes
+var sha256CryptOrder = [][3]int{
+	{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+	{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+}
+
+var sha512CryptOrder = [][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+	{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+	{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+	{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+	{62, 20, 41},
+}
+
+// SHACryptHash is a SHA-crypt hash, salt and hash are kept in their crypt encoding.
+type SHACryptHash struct {
+	Variant string
+	Rounds  int
+	// RoundsSet is true if the rounds are explicitly given in the string
+	RoundsSet bool
+	Salt      string
+	Hash      string
+}
+
+func isValidSHACryptVariant(v string) bool {
+	for _, candidate := range SHACryptVariants {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+func newInvalidSHACryptHashError(message string) error {
+	return fmt.Errorf("%s: %w", message, ErrInvalidSHACryptHash)
+}
+
+func (h *SHACryptHash) ValidateParameters() error {
+	if !isValidSHACryptVariant(h.Variant) {
+		return NewMismatchedFunctionNameError(h.Variant, SHACryptVariants...)
+	}
+	if h.Rounds < shaCryptMinRounds || h.Rounds > shaCryptMaxRounds {
+		return wrapParameterValueErrorToPHCError(fmt.Sprintf("must be between %d <= rounds <= %d, got %d",
+			shaCryptMinRounds, shaCryptMaxRounds, h.Rounds), "rounds", nil)
+	}
+	if strings.ContainsAny(h.Salt, "$\n:") {
+		return wrapParameterValueErrorToPHCError("salt must not contain '$'", "salt", nil)
+	}
+	return nil
+}
+
+// effectiveSalt returns the salt that is used, the specification truncates salts to 16 characters.
+func (h *SHACryptHash) effectiveSalt() string {
+	if len(h.Salt) > shaCryptMaxSaltLength {
+		return h.Salt[:shaCryptMaxSaltLength]
+	}
+	return h.Salt
+}
+
+func DecodeSHACrypt(s string) (*SHACryptHash, error) {
+	return decodeSHACrypt(s, DefaultLimits)
+}
+
+func decodeSHACrypt(s string, limits *Limits) (*SHACryptHash, error) {
+	if limitErr := limits.CheckString(s); limitErr != nil {
+		return nil, limitErr
+	}
+	if !strings.HasPrefix(s, "$") {
+		return nil, newInvalidSHACryptHashError("hash must begin with \"$\"")
+	}
+	split := strings.Split(s[1:], "$")
+	variant := split[0]
+	if !isValidSHACryptVariant(variant) {
+		return nil, NewMismatchedFunctionNameError(variant, SHACryptVariants...)
+	}
+	split = split[1:]
+	res := &SHACryptHash{
+		Variant: variant,
+		Rounds:  shaCryptDefaultRounds,
+	}
+	if len(split) > 0 && strings.HasPrefix(split[0], shaCryptRoundsPrefix) {
+		rounds, roundsErr := DecodeUnsignedString(split[0][len(shaCryptRoundsPrefix):], false, 31)
+		if roundsErr != nil {
+			return nil, wrapParameterValueErrorToPHCError("can't parse as integer", "rounds", roundsErr)
+		}
+		// rounds out of range are clamped, this is what the specification demands
+		res.Rounds = int(rounds)
+		if res.Rounds < shaCryptMinRounds {
+			res.Rounds = shaCryptMinRounds
+		}
+		if res.Rounds > shaCryptMaxRounds {
+			res.Rounds = shaCryptMaxRounds
+		}
+		res.RoundsSet = true
+		split = split[1:]
+	}
+	switch len(split) {
+	case 1:
+		res.Salt = split[0]
+	case 2:
+		res.Salt = split[0]
+		res.Hash = split[1]
+		if onlyValidRunes, invalidRune := validateRuneFunc(isValidCryptRune, res.Hash); !onlyValidRunes {
+			return nil, newInvalidSHACryptHashError(fmt.Sprintf("invalid character \"%s\" in hash", string(invalidRune)))
+		}
+	default:
+		return nil, newInvalidSHACryptHashError("hash must be of the form $<variant>$[rounds=<rounds>$]<salt>$<hash>")
+	}
+	res.Salt = res.effectiveSalt()
+	if err := res.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	if limitErr := limits.CheckSHACrypt(res); limitErr != nil {
+		return nil, limitErr
+	}
+	return res, nil
+}
+
+func isValidCryptRune(r rune) bool {
+	return strings.ContainsRune(cryptAlphabet, r)
+}
+
+// Encode returns the SHA-crypt string.
+// If no hash is set the result is the setting string (variant, rounds and salt) only.
+func (h *SHACryptHash) Encode() (string, error) {
+	if err := h.ValidateParameters(); err != nil {
+		return "", err
+	}
+	var buffer strings.Builder
+	buffer.WriteRune('$')
+	buffer.WriteString(h.Variant)
+	buffer.WriteRune('$')
+	if h.RoundsSet {
+		buffer.WriteString(shaCryptRoundsPrefix)
+		buffer.WriteString(strconv.Itoa(h.Rounds))
+		buffer.WriteRune('$')
+	}
+	buffer.WriteString(h.effectiveSalt())
+	if h.Hash != "" {
+		buffer.WriteRune('$')
+		buffer.WriteString(h.Hash)
+	}
+	return buffer.String(), nil
+}
+
+// Verify checks if the password matches the hash.
+func (h *SHACryptHash) Verify(password []byte) (bool, error) {
+	return h.verifyLimits(context.Background(), password, DefaultLimits)
+}
+
+func (h *SHACryptHash) verifyLimits(_ context.Context, password []byte, limits *Limits) (bool, error) {
+	if err := h.ValidateParameters(); err != nil {
+		return false, err
+	}
+	if h.Hash == "" {
+		return false, NewPHCError("can't verify password", ErrMissingHash)
+	}
+	if limitErr := limits.CheckSHACrypt(h); limitErr != nil {
+		return false, limitErr
+	}
+	computed := shaCrypt(h.Variant, password, []byte(h.effectiveSalt()), h.Rounds)
+	return equalAndWipe(computed, []byte(h.Hash)), nil
+}
+
+// writeRepeated writes src to h until length bytes are written.
+func writeRepeated(h hash.Hash, src []byte, length int) {
+	for ; length > len(src); length -= len(src) {
+		h.Write(src)
+	}
+	h.Write(src[:length])
+}
+
+// shaCrypt computes the crypt encoded hash, variant must be "5" or "6".
+func shaCrypt(variant string, password, salt []byte, rounds int) []byte {
+	newHash := sha256.New
+	order := sha256CryptOrder
+	if variant == "6" {
+		newHash = sha512.New
+		order = sha512CryptOrder
+	}
+
+	// digest B
+	h := newHash()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(password)
+	digestB := h.Sum(nil)
+
+	// digest A
+	h.Reset()
+	h.Write(password)
+	h.Write(salt)
+	writeRepeated(h, digestB, len(password))
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write(digestB)
+		} else {
+			h.Write(password)
+		}
+	}
+	digestA := h.Sum(nil)
+
+	// sequence P
+	h.Reset()
+	for i := 0; i < len(password); i++ {
+		h.Write(password)
+	}
+	digestDP := h.Sum(nil)
+	seqP := make([]byte, 0, len(password))
+	for len(seqP)+len(digestDP) < len(password) {
+		seqP = append(seqP, digestDP...)
+	}
+	seqP = append(seqP, digestDP[:len(password)-len(seqP)]...)
+
+	// sequence S
+	h.Reset()
+	for i := 0; i < 16+int(digestA[0]); i++ {
+		h.Write(salt)
+	}
+	digestDS := h.Sum(nil)
+	seqS := make([]byte, 0, len(salt))
+	for len(seqS)+len(digestDS) < len(salt) {
+		seqS = append(seqS, digestDS...)
+	}
+	seqS = append(seqS, digestDS[:len(salt)-len(seqS)]...)
+
+	digestC := digestA
+	for i := 0; i < rounds; i++ {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(seqP)
+		} else {
+			h.Write(digestC)
+		}
+		if i%3 != 0 {
+			h.Write(seqS)
+		}
+		if i%7 != 0 {
+			h.Write(seqP)
+		}
+		if i&1 != 0 {
+			h.Write(digestC)
+		} else {
+			h.Write(seqP)
+		}
+		digestC = h.Sum(digestC[:0])
+	}
+
+	res := make([]byte, 0, 86)
+	for _, triple := range order {
+		res = appendCrypt24(res, digestC[triple[0]], digestC[triple[1]], digestC[triple[2]], 4)
+	}
+	if variant == "6" {
+		res = appendCrypt24(res, 0, 0, digestC[63], 2)
+	} else {
+		res = appendCrypt24(res, 0, digestC[31], digestC[30], 3)
+	}
+	return res
+}
+
+func appendCrypt24(dst []byte, b2, b1, b0 byte, n int) []byte {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for ; n > 0; n-- {
+		dst = append(dst, cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+	return dst
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Spring Security's DelegatingPasswordEncoder prefixes each hash with the id of the encoder in curly braces,
+// for example "{bcrypt}$2a$10$..." or "{argon2}$argon2id$v=19$...".
+
+var (
+	ErrInvalidSpringHash = errors.New("invalid spring security hash")
+	ErrUnknownSpringID   = errors.New("unknown spring security encoder id")
+)
+
+// SpringSchemes maps the encoder ids of Spring Security to the function names the encoded hash may have.
+// The hashes are decoded with the DefaultRegistry.
+//
+// The id "scrypt" is handled separately because Spring uses its own layout, see DecodeSpringScrypt.
+var SpringSchemes = map[string][]string{
+	"bcrypt": BcryptVariants,
+	"argon2": Argon2Variants,
+}
+
+// SpringScryptID is the id of Spring's SCryptPasswordEncoder.
+const SpringScryptID = "scrypt"
+
+// SpringPasswordHash is a hash with the "{id}" prefix of Spring's DelegatingPasswordEncoder.
+type SpringPasswordHash struct {
+	ID   string
+	Hash PasswordHash
+}
+
+func newInvalidSpringHashError(message string) error {
+	return fmt.Errorf("%s: %w", message, ErrInvalidSpringHash)
+}
+
+// SplitSpringID splits "{id}rest" into id and rest.
+func SplitSpringID(s string) (string, string, error) {
+	if !strings.HasPrefix(s, "{") {
+		return "", "", newInvalidSpringHashError("hash must begin with \"{\"")
+	}
+	end := strings.IndexRune(s, '}')
+	if end < 0 {
+		return "", "", newInvalidSpringHashError("no closing \"}\" found")
+	}
+	return s[1:end], s[end+1:], nil
+}
+
+// DecodeSpring decodes a hash with a "{id}" prefix.
+func DecodeSpring(s string) (*SpringPasswordHash, error) {
+	if limitErr := DefaultLimits.CheckString(s); limitErr != nil {
+		return nil, limitErr
+	}
+	id, encoded, splitErr := SplitSpringID(s)
+	if splitErr != nil {
+		return nil, splitErr
+	}
+	if id == SpringScryptID {
+		phc, scryptErr := DecodeSpringScrypt(encoded)
+		if scryptErr != nil {
+			return nil, scryptErr
+		}
+		return &SpringPasswordHash{ID: id, Hash: phc}, nil
+	}
+	functionNames, has := SpringSchemes[id]
+	if !has {
+		return nil, fmt.Errorf("id \"%s\": %w", id, ErrUnknownSpringID)
+	}
+	functionName, nameErr := FunctionName(encoded)
+	if nameErr != nil {
+		return nil, nameErr
+	}
+	found := false
+	for _, candidate := range functionNames {
+		if candidate == functionName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, NewMismatchedFunctionNameError(functionName, functionNames...)
+	}
+	h, decodeErr := DefaultRegistry.Decode(encoded)
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return &SpringPasswordHash{ID: id, Hash: h}, nil
+}
+
+// Encode returns the hash with its "{id}" prefix.
+func (h *SpringPasswordHash) Encode() (string, error) {
+	var encoded string
+	var err error
+	if h.ID == SpringScryptID {
+		phc, ok := h.Hash.(*ScryptPHC)
+		if !ok {
+			return "", newInvalidSpringHashError("scrypt hash must be of type *ScryptPHC")
+		}
+		encoded, err = EncodeSpringScrypt(phc)
+	} else {
+		encoded, err = h.Hash.Encode()
+	}
+	if err != nil {
+		return "", err
+	}
+	return "{" + h.ID + "}" + encoded, nil
+}
+
+// Verify checks if the password matches the hash.
+func (h *SpringPasswordHash) Verify(password []byte) (bool, error) {
+	return h.Hash.Verify(password)
+}
+
+// VerifyContext is like Verify, but stops early if ctx is done, see VerifyContext.
+func (h *SpringPasswordHash) VerifyContext(ctx context.Context, password []byte) (bool, error) {
+	return VerifyContext(ctx, h.Hash, password)
+}
+
+func (h *SpringPasswordHash) verifyLimits(ctx context.Context, password []byte, limits *Limits) (bool, error) {
+	return verifyWithLimits(ctx, h.Hash, password, limits)
+}
+
+// DecodeSpringScrypt decodes a hash created by Spring's SCryptPasswordEncoder.
+//
+// The format is $<params in hex>$<salt>$<hash> where params = log2(N) << 16 | r << 8 | p, salt and hash are
+// encoded in standard base64 with padding.
+func DecodeSpringScrypt(s string) (*ScryptPHC, error) {
+	if !strings.HasPrefix(s, "$") {
+		return nil, newInvalidSpringHashError("scrypt hash must begin with \"$\"")
+	}
+	split := strings.Split(s[1:], "$")
+	if len(split) != 3 {
+		return nil, newInvalidSpringHashError("scrypt hash must be of the form $<params>$<salt>$<hash>")
+	}
+	params, paramsErr := strconv.ParseUint(split[0], 16, 64)
+	if paramsErr != nil {
+		return nil, wrapParameterValueErrorToPHCError("can't parse as hex integer", "params", paramsErr)
+	}
+	ln := int(params >> 16 & 0xffff)
+	if ln <= 0 || ln > (strconv.IntSize-2) {
+		return nil, wrapParameterValueErrorToPHCError(fmt.Sprintf("invalid cost 2^(%d)", ln), "ln", nil)
+	}
+	salt, saltErr := base64.StdEncoding.DecodeString(split[1])
+	if saltErr != nil {
+		return nil, NewPHCError("error decoding salt from base64 string", newBase64DecodeErrorWrapper(saltErr))
+	}
+	hash, hashErr := base64.StdEncoding.DecodeString(split[2])
+	if hashErr != nil {
+		return nil, NewPHCError("error decoding hash from base64", newBase64DecodeErrorWrapper(hashErr))
+	}
+	res := &ScryptPHC{
+		Cost:        1 << ln,
+		BlockSize:   int(params >> 8 & 0xff),
+		Parallelism: int(params & 0xff),
+		Salt:        salt,
+		SaltString:  string(Base64Encode(salt)),
+		Hash:        hash,
+		HashString:  string(Base64Encode(hash)),
+	}
+	if err := res.ValidateParameters(); err != nil {
+		return nil, err
+	}
+	if limitErr := DefaultLimits.CheckScrypt(res); limitErr != nil {
+		return nil, limitErr
+	}
+	return res, nil
+}
+
+// EncodeSpringScrypt encodes the scrypt hash in the format of Spring's SCryptPasswordEncoder.
+// The block size and parallelism must be < 256.
+func EncodeSpringScrypt(phc *ScryptPHC) (string, error) {
+	if err := phc.ValidateParameters(); err != nil {
+		return "", err
+	}
+	if phc.BlockSize > 0xff || phc.Parallelism > 0xff {
+		return "", wrapMultipleParametersValueErrorToPHCError("spring scrypt encoding requires r and p < 256", nil,
+			"r", "p")
+	}
+	ln := uint64(bits.TrailingZeros(uint(phc.Cost)))
+	params := ln<<16 | uint64(phc.BlockSize)<<8 | uint64(phc.Parallelism)
+	return "$" + strconv.FormatUint(params, 16) + "$" + base64.StdEncoding.EncodeToString(phc.Salt) +
+		"$" + base64.StdEncoding.EncodeToString(phc.Hash), nil
+}
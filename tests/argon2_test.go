@@ -0,0 +1,202 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+	"golang.org/x/crypto/argon2"
+)
+
+func repeatedBytes(b byte, n int) []byte {
+	return bytes.Repeat([]byte{b}, n)
+}
+
+// test vectors from RFC 9106, section 5
+var argon2RFCTests = []struct {
+	variant  string
+	expected string
+}{
+	{"argon2d", "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"},
+	{"argon2i", "c814d9d1dc7f37aa13f0d77f2494bda1c8de6b016dd388d29952a4c4672b6ce8"},
+	{"argon2id", "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659"},
+}
+
+func TestArgon2KeyRFC9106(t *testing.T) {
+	password := repeatedBytes(0x01, 32)
+	salt := repeatedBytes(0x02, 16)
+	secret := repeatedBytes(0x03, 8)
+	data := repeatedBytes(0x04, 12)
+	for _, tc := range argon2RFCTests {
+		key, err := gophc.Argon2Key(tc.variant, 0x13, password, salt, secret, data, 3, 32, 4, 32)
+		if err != nil {
+			t.Errorf("unexpected error computing %s: %v", tc.variant, err)
+			continue
+		}
+		if encoded := hex.EncodeToString(key); encoded != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.variant, tc.expected, encoded)
+		}
+	}
+}
+
+// test vectors from the reference implementation, all for the password "password"
+var argon2PHCTests = []string{
+	"$argon2i$m=65536,t=2,p=1$c29tZXNhbHQ$9sTbSlTio3Biev89thdrlKKiCaYsjjYVJxGAL3swxpQ",
+	"$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA",
+	"$argon2i$v=19$m=256,t=2,p=2$c29tZXNhbHQ$T/XOJ2mh1/TIpJHfCdQan76Q5esCFVoT5MAeIM1Oq2E",
+	"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+}
+
+func TestArgon2Verify(t *testing.T) {
+	for _, in := range argon2PHCTests {
+		phc, err := gophc.DecodeArgon2(in)
+		if err != nil {
+			t.Errorf("unexpected error decoding \"%s\": %v", in, err)
+			continue
+		}
+		ok, verifyErr := phc.Verify([]byte("password"))
+		if verifyErr != nil || !ok {
+			t.Errorf("expected password to match \"%s\", got %v (error %v)", in, ok, verifyErr)
+		}
+		encoded, encodeErr := phc.Encode()
+		if encodeErr != nil || encoded != in {
+			t.Errorf("expected encoding \"%s\", got \"%s\" (error %v)", in, encoded, encodeErr)
+		}
+	}
+}
+
+func TestArgon2KeyCompatible(t *testing.T) {
+	password, salt := []byte("password"), []byte("somesalt")
+	for _, threads := range []uint8{1, 3, 4} {
+		expected := argon2.IDKey(password, salt, 3, 1024, threads, 24)
+		key, err := gophc.Argon2Key("argon2id", 0x13, password, salt, nil, nil, 3, 1024, uint32(threads), 24)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(key, expected) {
+			t.Errorf("argon2id with %d threads: expected %x, got %x", threads, expected, key)
+		}
+	}
+}
+
+func TestArgon2KeyIDAndData(t *testing.T) {
+	template := &gophc.Argon2PHC{
+		Variant: "argon2id",
+		Version: 0x13,
+		M:       64,
+		T:       1,
+		P:       1,
+		KeyID:   []byte{1, 2, 3, 4},
+		Data:    []byte("associated"),
+		Salt:    []byte("somesaltsomesalt"),
+	}
+	secret := []byte("secret key")
+	hash, err := gophc.Argon2Key(template.Variant, template.Version, []byte("password"), template.Salt, secret,
+		template.Data, template.T, template.M, uint32(template.P), 32)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	template.Hash = hash
+	encoded, encodeErr := template.Encode()
+	if encodeErr != nil {
+		t.Fatalf("unexpected error encoding: %v", encodeErr)
+	}
+	decoded, decodeErr := gophc.DecodeArgon2(encoded)
+	if decodeErr != nil {
+		t.Fatalf("unexpected error decoding \"%s\": %v", encoded, decodeErr)
+	}
+	if !bytes.Equal(decoded.KeyID, template.KeyID) || !bytes.Equal(decoded.Data, template.Data) {
+		t.Errorf("keyid and data of \"%s\" not decoded correctly", encoded)
+	}
+	if ok, verifyErr := decoded.VerifyWithSecret([]byte("password"), secret); verifyErr != nil || !ok {
+		t.Errorf("expected password to match \"%s\", got %v (error %v)", encoded, ok, verifyErr)
+	}
+	if ok, verifyErr := decoded.Verify([]byte("password")); verifyErr != nil || ok {
+		t.Errorf("expected password without secret not to match \"%s\", got %v (error %v)", encoded, ok, verifyErr)
+	}
+}
+
+func TestArgon2ValidateParameters(t *testing.T) {
+	valid := func() *gophc.Argon2PHC {
+		return &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 8,
+			Salt: make([]byte, 16), Hash: make([]byte, 32)}
+	}
+	if err := valid().ValidateParameters(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	invalid := []func(phc *gophc.Argon2PHC){
+		func(phc *gophc.Argon2PHC) { phc.M = 63 },
+		func(phc *gophc.Argon2PHC) { phc.T = 0 },
+		func(phc *gophc.Argon2PHC) { phc.P = 0 },
+		func(phc *gophc.Argon2PHC) { phc.P = 1 << 24; phc.M = 1 << 30 },
+		func(phc *gophc.Argon2PHC) { phc.Salt = make([]byte, 7) },
+		func(phc *gophc.Argon2PHC) { phc.Hash = make([]byte, 3) },
+		func(phc *gophc.Argon2PHC) { phc.KeyID = make([]byte, 9) },
+		func(phc *gophc.Argon2PHC) { phc.Data = make([]byte, 33) },
+	}
+	for i, modify := range invalid {
+		phc := valid()
+		modify(phc)
+		if err := phc.ValidateParameters(); !errors.Is(err, gophc.ErrParameterValueValidation) {
+			t.Errorf("case %d: expected ErrParameterValueValidation, got %v", i, err)
+		}
+	}
+}
+
+func TestArgon2KeyIDDataEncoding(t *testing.T) {
+	const in = "$argon2id$v=19$m=65536,t=2,p=1,keyid=a2V5,data=ZGF0YQ$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	phc, err := gophc.DecodeArgon2(in)
+	if err != nil {
+		t.Fatalf("unexpected error decoding \"%s\": %v", in, err)
+	}
+	if string(phc.KeyID) != "key" || string(phc.Data) != "data" {
+		t.Errorf("expected keyid \"key\" and data \"data\", got \"%s\" and \"%s\"", phc.KeyID, phc.Data)
+	}
+	if encoded, encodeErr := phc.Encode(); encodeErr != nil || encoded != in {
+		t.Errorf("expected encoding \"%s\", got \"%s\" (error %v)", in, encoded, encodeErr)
+	}
+	// empty keyid and data are omitted
+	phc.KeyID, phc.Data = nil, nil
+	const withoutKeyID = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	if encoded, encodeErr := phc.Encode(); encodeErr != nil || encoded != withoutKeyID {
+		t.Errorf("expected encoding \"%s\", got \"%s\" (error %v)", withoutKeyID, encoded, encodeErr)
+	}
+
+	phc.KeyID, phc.Data = make([]byte, gophc.Argon2MaxKeyIDLength), make([]byte, gophc.Argon2MaxDataLength)
+	if validationErr := phc.ValidateParameters(); validationErr != nil {
+		t.Errorf("unexpected validation error for maximum keyid and data length: %v", validationErr)
+	}
+	phc.KeyID = make([]byte, gophc.Argon2MaxKeyIDLength+1)
+	if validationErr := phc.ValidateParameters(); !errors.Is(validationErr, gophc.ErrParameterValueValidation) {
+		t.Errorf("expected ErrParameterValueValidation for keyid, got %v", validationErr)
+	}
+	phc.KeyID, phc.Data = nil, make([]byte, gophc.Argon2MaxDataLength+1)
+	if validationErr := phc.ValidateParameters(); !errors.Is(validationErr, gophc.ErrParameterValueValidation) {
+		t.Errorf("expected ErrParameterValueValidation for data, got %v", validationErr)
+	}
+
+	for _, invalid := range []string{
+		"$argon2id$v=19$m=65536,t=2,p=1,keyid=a$c29tZXNhbHQ",
+		"$argon2id$v=19$m=65536,t=2,p=1,data=a$c29tZXNhbHQ",
+	} {
+		if _, decodeErr := gophc.DecodeArgon2(invalid); !errors.Is(decodeErr, gophc.ErrBase64Decode) {
+			t.Errorf("expected ErrBase64Decode for \"%s\", got %v", invalid, decodeErr)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const aspNetPassword = "correct horse battery staple"
+
+func aspNetTestSalt() []byte {
+	return []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+}
+
+func TestASPNetIdentityV2(t *testing.T) {
+	salt := aspNetTestSalt()
+	subkey := pbkdf2.Key([]byte(aspNetPassword), salt, 1000, 32, sha1.New)
+	blob := append([]byte{0x00}, salt...)
+	blob = append(blob, subkey...)
+	encoded := base64.StdEncoding.EncodeToString(blob)
+
+	phc, err := gophc.DecodeASPNetIdentity(encoded)
+	if err != nil {
+		t.Fatalf("unexpected error decoding version 2 hash: %v", err)
+	}
+	if phc.Variant != "pbkdf2-sha1" || phc.Iterations != 1000 {
+		t.Errorf("expected pbkdf2-sha1 with 1000 iterations, got %s with %d iterations", phc.Variant, phc.Iterations)
+	}
+	ok, verifyErr := phc.Verify([]byte(aspNetPassword))
+	if verifyErr != nil || !ok {
+		t.Errorf("expected password to match version 2 hash, got %v (error %v)", ok, verifyErr)
+	}
+	reEncoded, encodeErr := gophc.EncodeASPNetIdentity(phc, gophc.ASPNetIdentityV2)
+	if encodeErr != nil {
+		t.Fatalf("unexpected error encoding version 2 hash: %v", encodeErr)
+	}
+	if reEncoded != encoded {
+		t.Errorf("expected encoding \"%s\", got \"%s\"", encoded, reEncoded)
+	}
+}
+
+func TestASPNetIdentityV3(t *testing.T) {
+	salt := aspNetTestSalt()
+	subkey := pbkdf2.Key([]byte(aspNetPassword), salt, 10000, 32, sha256.New)
+	blob := make([]byte, 13)
+	blob[0] = 0x01
+	binary.BigEndian.PutUint32(blob[1:5], 1)
+	binary.BigEndian.PutUint32(blob[5:9], 10000)
+	binary.BigEndian.PutUint32(blob[9:13], uint32(len(salt)))
+	blob = append(blob, salt...)
+	blob = append(blob, subkey...)
+	encoded := base64.StdEncoding.EncodeToString(blob)
+
+	ok, verifyErr := gophc.VerifyASPNetIdentity(encoded, []byte(aspNetPassword))
+	if verifyErr != nil || !ok {
+		t.Errorf("expected password to match version 3 hash, got %v (error %v)", ok, verifyErr)
+	}
+	ok, verifyErr = gophc.VerifyASPNetIdentity(encoded, []byte("wrong"))
+	if verifyErr != nil || ok {
+		t.Errorf("expected wrong password not to match version 3 hash, got %v (error %v)", ok, verifyErr)
+	}
+
+	// the phc representation must round trip
+	phc, _ := gophc.DecodeASPNetIdentity(encoded)
+	phcString, encodeErr := phc.Encode()
+	if encodeErr != nil {
+		t.Fatalf("unexpected error encoding phc string: %v", encodeErr)
+	}
+	decoded, decodeErr := gophc.DecodePBKDF2(phcString)
+	if decodeErr != nil {
+		t.Fatalf("unexpected error decoding phc string \"%s\": %v", phcString, decodeErr)
+	}
+	if decoded.Variant != "pbkdf2-sha256" || decoded.Iterations != 10000 || !bytes.Equal(decoded.Hash, subkey) {
+		t.Errorf("phc string \"%s\" was not decoded correctly", phcString)
+	}
+	reEncoded, reEncodeErr := gophc.EncodeASPNetIdentity(decoded, gophc.ASPNetIdentityV3)
+	if reEncodeErr != nil {
+		t.Fatalf("unexpected error encoding version 3 hash: %v", reEncodeErr)
+	}
+	if reEncoded != encoded {
+		t.Errorf("expected encoding \"%s\", got \"%s\"", encoded, reEncoded)
+	}
+}
+
+func TestASPNetIdentityInvalid(t *testing.T) {
+	for _, in := range []string{"", "AgAAAA==", "AQAAAAEAACcQAAAAEA==", "not base64!"} {
+		if _, err := gophc.DecodeASPNetIdentity(in); !errors.Is(err, gophc.ErrInvalidASPNetIdentityHash) {
+			t.Errorf("expected ErrInvalidASPNetIdentityHash for input \"%s\", got %v", in, err)
+		}
+	}
+}
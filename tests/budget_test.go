@@ -0,0 +1,98 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestMemoryBudgetReject(t *testing.T) {
+	budget := gophc.NewMemoryBudget(100, gophc.BudgetReject)
+	ctx := context.Background()
+	if err := budget.Acquire(ctx, 60); err != nil {
+		t.Fatal(err)
+	}
+	if err := budget.Acquire(ctx, 60); !errors.Is(err, gophc.ErrMemoryBudgetExceeded) {
+		t.Errorf("expected budget error, got %v", err)
+	}
+	budget.Release(60)
+	if err := budget.Acquire(ctx, 60); err != nil {
+		t.Errorf("expected to acquire after release, got %v", err)
+	}
+	if err := budget.Acquire(ctx, 101); !errors.Is(err, gophc.ErrMemoryBudgetExceeded) {
+		t.Errorf("expected budget error, got %v", err)
+	}
+}
+
+func TestMemoryBudgetQueue(t *testing.T) {
+	budget := gophc.NewMemoryBudget(100, gophc.BudgetQueue)
+	if err := budget.Acquire(context.Background(), 60); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := budget.Acquire(ctx, 60); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- budget.Acquire(context.Background(), 60)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	budget.Release(60)
+	if err := <-done; err != nil {
+		t.Errorf("expected queued request to be admitted, got %v", err)
+	}
+	if inUse := budget.InUse(); inUse != 60 {
+		t.Errorf("expected 60 bytes in use, got %d", inUse)
+	}
+}
+
+func TestMemoryBudgetCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, mode := range []gophc.BudgetMode{gophc.BudgetReject, gophc.BudgetQueue} {
+		budget := gophc.NewMemoryBudget(100, mode)
+		if err := budget.Acquire(ctx, 60); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context canceled, got %v", err)
+		}
+		if inUse := budget.InUse(); inUse != 0 {
+			t.Errorf("expected nothing in use, got %d", inUse)
+		}
+	}
+}
+
+func TestBoundedVerifier(t *testing.T) {
+	template := &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1}
+	verifier := gophc.NewBoundedVerifier(nil, gophc.NewMemoryBudget(64*1024, gophc.BudgetReject))
+	h, err := verifier.NewHash(context.Background(), template, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := h.Encode()
+	ok, verifyErr := verifier.Verify(context.Background(), encoded, []byte("password"))
+	if verifyErr != nil || !ok {
+		t.Errorf("expected password to verify, got %v", verifyErr)
+	}
+	small := gophc.NewBoundedVerifier(nil, gophc.NewMemoryBudget(1024, gophc.BudgetReject))
+	if _, err := small.Verify(context.Background(), encoded, []byte("password")); !errors.Is(err, gophc.ErrMemoryBudgetExceeded) {
+		t.Errorf("expected budget error, got %v", err)
+	}
+}
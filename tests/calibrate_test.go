@@ -0,0 +1,80 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestCalibrateArgon2(t *testing.T) {
+	opts := gophc.CalibrationOptions{
+		TargetDuration: 20 * time.Millisecond,
+		MaxMemory:      16 * 1024 * 1024,
+		Cores:          2,
+	}
+	phc, res, err := gophc.CalibrateArgon2(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := phc.ValidateParameters(); err != nil {
+		t.Errorf("calibrated parameters are invalid: %v", err)
+	}
+	if phc.Variant != "argon2id" || phc.P != 2 {
+		t.Errorf("expected argon2id with p=2, got %s with p=%d", phc.Variant, phc.P)
+	}
+	if phc.M > 16*1024 {
+		t.Errorf("memory ceiling exceeded: m=%d", phc.M)
+	}
+	if len(res.Measurements) == 0 || res.Duration != res.Measurements[len(res.Measurements)-1].Duration {
+		t.Errorf("invalid calibration result: %+v", res)
+	}
+}
+
+func TestCalibrateScrypt(t *testing.T) {
+	opts := gophc.CalibrationOptions{
+		TargetDuration: 20 * time.Millisecond,
+		MaxMemory:      8 * 1024 * 1024,
+	}
+	phc, res, err := gophc.CalibrateScrypt(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := phc.ValidateParameters(); err != nil {
+		t.Errorf("calibrated parameters are invalid: %v", err)
+	}
+	if memory := 128 * phc.Cost * phc.BlockSize; memory > 8*1024*1024 {
+		t.Errorf("memory ceiling exceeded: %d bytes", memory)
+	}
+	if len(res.Measurements) == 0 {
+		t.Error("expected measurements")
+	}
+}
+
+func TestCalibrateInvalidOptions(t *testing.T) {
+	tests := []gophc.CalibrationOptions{
+		{TargetDuration: 0, MaxMemory: 1024 * 1024},
+		{TargetDuration: time.Second, MaxMemory: 0},
+		{TargetDuration: time.Second, MaxMemory: 1024, Cores: 4},
+	}
+	for _, opts := range tests {
+		if _, _, err := gophc.CalibrateArgon2(opts); !errors.Is(err, gophc.ErrInvalidCalibrationOptions) {
+			t.Errorf("expected invalid options error for %+v, got %v", opts, err)
+		}
+	}
+}
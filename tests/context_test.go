@@ -0,0 +1,90 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FabianWe/gophc"
+)
+
+// test vectors from RFC 7914, section 12
+var scryptKeyTests = []struct {
+	password, salt string
+	n, r, p        int
+	expected       string
+}{
+	{"", "", 16, 1, 1,
+		"77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+	{"password", "NaCl", 1024, 8, 16,
+		"fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	{"pleaseletmein", "SodiumChloride", 16384, 8, 1,
+		"7023bdcb3afd7348461c06cd81fd38ebfda8fbba904f8e3ea9b543f6545da1f2d5432955613f0fcf62d49705242a9af9e61e85dc0d651e40dfcf017b45575887"},
+}
+
+func TestScryptKeyContext(t *testing.T) {
+	for _, tc := range scryptKeyTests {
+		key, err := gophc.ScryptKeyContext(context.Background(), []byte(tc.password), []byte(tc.salt), tc.n, tc.r, tc.p, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(key); got != tc.expected {
+			t.Errorf("scrypt(%q, %q, %d, %d, %d): expected %s, got %s", tc.password, tc.salt, tc.n, tc.r, tc.p,
+				tc.expected, got)
+		}
+	}
+}
+
+func TestContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	argon2Template := &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1}
+	if _, err := argon2Template.HashContext(ctx, []byte("password")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	scryptTemplate := &gophc.ScryptPHC{Cost: 1 << 10, BlockSize: 8, Parallelism: 1}
+	if _, err := scryptTemplate.HashContext(ctx, []byte("password")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	h, err := scryptTemplate.HashPassword([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := h.Encode()
+	if _, err := gophc.DefaultRegistry.VerifyContext(ctx, encoded, []byte("password")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	ok, verifyErr := gophc.DefaultRegistry.VerifyContext(context.Background(), encoded, []byte("password"))
+	if verifyErr != nil || !ok {
+		t.Errorf("expected password to verify, got %v", verifyErr)
+	}
+}
+
+func TestContextDeadlineArgon2(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	phc := &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64 * 1024, T: 64, P: 1}
+	start := time.Now()
+	if _, err := phc.HashContext(ctx, []byte("password")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("computation wasn't stopped early, took %s", elapsed)
+	}
+}
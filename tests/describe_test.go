@@ -0,0 +1,83 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		in       uint64
+		expected string
+	}{
+		{0, "0 B"},
+		{1000, "1000 B"},
+		{1536 * 1024, "1536 KiB"},
+		{64 * 1024 * 1024, "64 MiB"},
+		{2 * 1024 * 1024 * 1024, "2 GiB"},
+	}
+	for _, tc := range tests {
+		if got := gophc.FormatBytes(tc.in); got != tc.expected {
+			t.Errorf("FormatBytes(%d): expected %q, got %q", tc.in, tc.expected, got)
+		}
+	}
+}
+
+func TestDescribeRedacted(t *testing.T) {
+	argon2String := "$argon2id$v=19$m=65536,t=3,p=4$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG"
+	argon2Hash, err := gophc.DecodeArgon2(argon2String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scryptHash, scryptErr := gophc.DecodeScrypt("$scrypt$ln=16,r=8,p=1$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E")
+	if scryptErr != nil {
+		t.Fatal(scryptErr)
+	}
+	instance, instanceErr := gophc.Argon2Schema.Decode(argon2String)
+	if instanceErr != nil {
+		t.Fatal(instanceErr)
+	}
+	tests := []struct {
+		value    interface{}
+		expected string
+		secrets  []string
+	}{
+		{argon2Hash, "argon2id v=19, m=64 MiB, t=3, p=4, 8-byte salt, 24-byte hash",
+			[]string{"c29tZXNhbHQ", "RdescudvJCsgt3ub"}},
+		{scryptHash, "scrypt N=2^16, r=8, p=1, mem=64 MiB, 16-byte salt, 32-byte hash",
+			[]string{"aM15713r3Xsvxbi31lqr1Q", "nFNh2CVHVjNldFVK"}},
+		{instance, "argon2id v=19, m=65536, t=3, p=4, 8-byte salt, 24-byte hash",
+			[]string{"c29tZXNhbHQ", "RdescudvJCsgt3ub"}},
+	}
+	for _, tc := range tests {
+		if got := fmt.Sprint(tc.value); got != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, got)
+		}
+		verbose := fmt.Sprintf("%+v %#v %s %q %x", tc.value, tc.value, tc.value, tc.value, tc.value)
+		if !strings.Contains(verbose, "sha256:") {
+			t.Errorf("expected fingerprints in %q", verbose)
+		}
+		for _, secret := range tc.secrets {
+			if strings.Contains(verbose, secret) {
+				t.Errorf("%q leaked in %q", secret, verbose)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestDummyVerifier(t *testing.T) {
+	template := &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 256, T: 2, P: 2}
+	verifier, err := gophc.NewDummyVerifier(template, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, password := range []string{"", "password"} {
+		ok, err := verifier.Verify([]byte(password))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Errorf("dummy verifier returned true for %q", password)
+		}
+	}
+	first, _ := verifier.Hash()
+	second, _ := verifier.Hash()
+	if first != second || !strings.HasPrefix(first, "$argon2id$v=19$m=256,t=2,p=2$") {
+		t.Errorf("expected cached hash with template parameters, got %s and %s", first, second)
+	}
+}
+
+func TestDummyVerifierError(t *testing.T) {
+	old := gophc.DefaultSaltGenerator
+	defer func() { gophc.DefaultSaltGenerator = old }()
+	gophc.DefaultSaltGenerator = gophc.NewSaltGenerator(bytes.NewReader(nil))
+
+	template := &gophc.ScryptPHC{Cost: 16, BlockSize: 8, Parallelism: 1}
+	if _, err := gophc.NewDummyVerifier(template, nil); !errors.Is(err, gophc.ErrSaltGeneration) {
+		t.Errorf("expected salt generation error from NewDummyVerifier, got %v", err)
+	}
+	verifier := &gophc.DummyVerifier{Template: template}
+	if _, err := verifier.Verify([]byte("password")); !errors.Is(err, gophc.ErrSaltGeneration) {
+		t.Errorf("expected salt generation error, got %v", err)
+	}
+	// the error is not cached
+	gophc.DefaultSaltGenerator = old
+	if _, err := verifier.Verify([]byte("password")); err != nil {
+		t.Errorf("expected the fake hash to be computed after the error, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+const envelopeInner = "$scrypt$ln=4,r=8,p=1$c29tZXNhbHQ$0r8uxrVjUOKx0PEuVO0FAr+wHFGJ0DLrHe/AGNP3Ldw"
+
+func TestEnvelope(t *testing.T) {
+	inner, err := (&gophc.ScryptPHC{Cost: 16, BlockSize: 8, Parallelism: 1}).HashPassword([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	innerString, _ := inner.Encode()
+
+	oldRing, _ := gophc.NewStaticKeyRing([]byte("2020"), map[string][]byte{"2020": bytes.Repeat([]byte{1}, 32)})
+	e, encryptErr := gophc.EncryptEnvelope(oldRing, innerString)
+	if encryptErr != nil {
+		t.Fatal(encryptErr)
+	}
+	encoded, encodeErr := e.Encode()
+	if encodeErr != nil {
+		t.Fatal(encodeErr)
+	}
+	if !strings.HasPrefix(encoded, "$enc-aesgcm$kid=MjAyMA$") {
+		t.Errorf("unexpected envelope %s", encoded)
+	}
+	decoded, decodeErr := gophc.DecodeEnvelope(encoded)
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if decrypted, _ := decoded.Decrypt(oldRing); decrypted != innerString {
+		t.Errorf("expected %s, got %s", innerString, decrypted)
+	}
+
+	registry := gophc.NewDefaultRegistry()
+	registry.Register(gophc.NewEnvelopeDecoder(oldRing, nil), gophc.EnvelopeFunctionName)
+	if ok, verifyErr := registry.Verify(encoded, []byte("password")); verifyErr != nil || !ok {
+		t.Errorf("expected envelope to verify, got %v", verifyErr)
+	}
+	if ok, _ := registry.Verify(encoded, []byte("wrong")); ok {
+		t.Error("envelope verifies wrong password")
+	}
+
+	// re-encrypt under a new key, the old key can be removed afterwards
+	bothRing, _ := gophc.NewStaticKeyRing([]byte("2021"), map[string][]byte{
+		"2020": bytes.Repeat([]byte{1}, 32),
+		"2021": bytes.Repeat([]byte{2}, 32),
+	})
+	reencrypted, reencryptErr := gophc.ReencryptEnvelope(bothRing, encoded)
+	if reencryptErr != nil {
+		t.Fatal(reencryptErr)
+	}
+	newRing, _ := gophc.NewStaticKeyRing([]byte("2021"), map[string][]byte{"2021": bytes.Repeat([]byte{2}, 32)})
+	newRegistry := gophc.NewDefaultRegistry()
+	newRegistry.Register(gophc.NewEnvelopeDecoder(newRing, nil), gophc.EnvelopeFunctionName)
+	if ok, verifyErr := newRegistry.Verify(reencrypted, []byte("password")); verifyErr != nil || !ok {
+		t.Errorf("expected re-encrypted envelope to verify, got %v", verifyErr)
+	}
+	if _, verifyErr := newRegistry.Verify(encoded, []byte("password")); !errors.Is(verifyErr, gophc.ErrUnknownEnvelopeKey) {
+		t.Errorf("expected unknown key error, got %v", verifyErr)
+	}
+}
+
+func TestEnvelopeTampered(t *testing.T) {
+	ring, _ := gophc.NewStaticKeyRing([]byte("k"), map[string][]byte{"k": bytes.Repeat([]byte{1}, 16)})
+	e, err := gophc.EncryptEnvelope(ring, envelopeInner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Ciphertext[0] ^= 1
+	if _, err := e.Decrypt(ring); !errors.Is(err, gophc.ErrEnvelopeDecryption) {
+		t.Errorf("expected decryption error, got %v", err)
+	}
+}
+
+func TestEnvelopeKeyLength(t *testing.T) {
+	ring, err := gophc.NewStaticKeyRing([]byte("k"), map[string][]byte{"k": bytes.Repeat([]byte{1}, 20)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gophc.EncryptEnvelope(ring, envelopeInner); !errors.Is(err, gophc.ErrInvalidEnvelopeKey) {
+		t.Errorf("expected invalid key error for a 20 byte key, got %v", err)
+	}
+	if _, err := gophc.NewStaticKeyRing([]byte("k"), map[string][]byte{"k": nil}); err == nil {
+		t.Error("expected error for an empty key")
+	}
+}
+
+func TestEnvelopeNonce(t *testing.T) {
+	// the nonce must not depend on DefaultSaltGenerator, which can be deterministic
+	old := gophc.DefaultSaltGenerator
+	defer func() { gophc.DefaultSaltGenerator = old }()
+	gophc.DefaultSaltGenerator = gophc.NewSaltGenerator(bytes.NewReader(make([]byte, 64)))
+
+	ring, _ := gophc.NewStaticKeyRing([]byte("k"), map[string][]byte{"k": bytes.Repeat([]byte{1}, 32)})
+	first, err := gophc.EncryptEnvelope(ring, envelopeInner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := gophc.EncryptEnvelope(ring, envelopeInner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first.Nonce, second.Nonce) {
+		t.Errorf("nonce %x was used twice", first.Nonce)
+	}
+}
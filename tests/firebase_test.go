@@ -0,0 +1,80 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+// example values from https://github.com/firebase/scrypt
+const (
+	firebaseSignerKey     = "jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA=="
+	firebaseSaltSeparator = "Bw=="
+	firebaseSalt          = "42xEC+ixf3L2lw=="
+	firebaseHash          = "lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ=="
+	firebasePassword      = "user1password"
+)
+
+func TestFirebaseScryptVerify(t *testing.T) {
+	phc, err := gophc.NewFirebaseScryptPHC(firebaseHash, firebaseSalt, firebaseSignerKey, firebaseSaltSeparator, 8, 14)
+	if err != nil {
+		t.Fatalf("unexpected error creating firebase hash: %v", err)
+	}
+	ok, verifyErr := phc.Verify([]byte(firebasePassword))
+	if verifyErr != nil {
+		t.Fatalf("unexpected error verifying password: %v", verifyErr)
+	}
+	if !ok {
+		t.Error("expected password to match firebase hash")
+	}
+	ok, verifyErr = phc.Verify([]byte("user2password"))
+	if verifyErr != nil {
+		t.Fatalf("unexpected error verifying password: %v", verifyErr)
+	}
+	if ok {
+		t.Error("expected wrong password not to match firebase hash")
+	}
+}
+
+func TestFirebaseScryptRoundTrip(t *testing.T) {
+	phc, err := gophc.NewFirebaseScryptPHC(firebaseHash, firebaseSalt, firebaseSignerKey, firebaseSaltSeparator, 8, 14)
+	if err != nil {
+		t.Fatalf("unexpected error creating firebase hash: %v", err)
+	}
+	encoded, encodeErr := phc.Encode()
+	if encodeErr != nil {
+		t.Fatalf("unexpected error encoding firebase hash: %v", encodeErr)
+	}
+	decoded, decodeErr := gophc.DecodeFirebaseScrypt(encoded)
+	if decodeErr != nil {
+		t.Fatalf("unexpected error decoding \"%s\": %v", encoded, decodeErr)
+	}
+	ok, verifyErr := decoded.Verify([]byte(firebasePassword))
+	if verifyErr != nil {
+		t.Fatalf("unexpected error verifying password: %v", verifyErr)
+	}
+	if !ok {
+		t.Errorf("expected password to match decoded firebase hash \"%s\"", encoded)
+	}
+	reEncoded, reEncodeErr := decoded.Encode()
+	if reEncodeErr != nil {
+		t.Fatalf("unexpected error encoding firebase hash: %v", reEncodeErr)
+	}
+	if reEncoded != encoded {
+		t.Errorf("expected encoding \"%s\", got \"%s\"", encoded, reEncoded)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestUnorderedParameters(t *testing.T) {
+	const canonical = "$scrypt$ln=16,r=8,p=1$c29tZXNhbHQ"
+	schema := *gophc.ScryptPHCSchema
+	schema.UnorderedParameters = true
+
+	if _, err := gophc.ScryptPHCSchema.Decode("$scrypt$p=1,ln=16,r=8$c29tZXNhbHQ"); !errors.Is(err, gophc.ErrNonOptionalParameterMissing) {
+		t.Errorf("expected ordered schema to fail with ErrNonOptionalParameterMissing, got %v", err)
+	}
+	for _, in := range []string{canonical, "$scrypt$p=1,ln=16,r=8$c29tZXNhbHQ", "$scrypt$r=8,p=1,ln=16$c29tZXNhbHQ"} {
+		instance, err := schema.Decode(in)
+		if err != nil {
+			t.Errorf("unexpected error decoding %s: %v", in, err)
+			continue
+		}
+		encoded, encodeErr := schema.Encode(&instance)
+		if encodeErr != nil || encoded != canonical {
+			t.Errorf("expected encoding %s, got %s (error %v)", canonical, encoded, encodeErr)
+		}
+	}
+
+	tests := []struct {
+		unordered bool
+		in        string
+		expected  error
+	}{
+		{true, "$scrypt$p=1,ln=16,r=8,p=2", gophc.ErrDuplicateParameter},
+		{true, "$scrypt$p=1,x=2,ln=16,r=8", gophc.ErrUnmatchedParameterName},
+		{true, "$scrypt$p=1,ln=16", gophc.ErrNonOptionalParameterMissing},
+		{true, "$scrypt$p=1,ln=16,r=8,pid=a_b", gophc.ErrParameterValueValidation},
+		{false, "$scrypt$ln=16,r=8,p=1,p=2", gophc.ErrDuplicateParameter},
+		{false, "$scrypt$ln=16,ln=17,r=8,p=1", gophc.ErrDuplicateParameter},
+		{false, "$scrypt$ln=16,r=8,p=1,ln=17", gophc.ErrDuplicateParameter},
+		{false, "$scrypt$ln=16,r=8,p=1,norm=nfc,pid=YQ", gophc.ErrUnmatchedParameterName},
+		{false, "$scrypt$ln=16,r=8,x=2,p=1", gophc.ErrUnmatchedParameterName},
+	}
+	for _, tc := range tests {
+		decoder := gophc.ScryptPHCSchema
+		if tc.unordered {
+			decoder = &schema
+		}
+		if _, err := decoder.Decode(tc.in); !errors.Is(err, tc.expected) {
+			t.Errorf("%s: expected error %v, got %v", tc.in, tc.expected, err)
+		}
+	}
+}
+
+func TestUnknownParameters(t *testing.T) {
+	const in = "$scrypt$ln=16,future=1,r=8,p=1,other=x$c29tZXNhbHQ"
+	if _, err := gophc.ScryptPHCSchema.Decode(in); !errors.Is(err, gophc.ErrUnmatchedParameterName) {
+		t.Errorf("expected ErrUnmatchedParameterName by default, got %v", err)
+	}
+
+	tests := []struct {
+		policy      gophc.UnknownParameterPolicy
+		unordered   bool
+		in          string
+		expected    string
+		extraLength int
+	}{
+		{gophc.IgnoreUnknownParameters, false, in, "$scrypt$ln=16,r=8,p=1$c29tZXNhbHQ", 0},
+		{gophc.PreserveUnknownParameters, false, in, "$scrypt$ln=16,r=8,p=1,future=1,other=x$c29tZXNhbHQ", 2},
+		{gophc.PreserveUnknownParameters, true, "$scrypt$other=x,p=1,ln=16,r=8$c29tZXNhbHQ", "$scrypt$ln=16,r=8,p=1,other=x$c29tZXNhbHQ", 1},
+	}
+	for _, tc := range tests {
+		schema := *gophc.ScryptPHCSchema
+		schema.UnknownParameters = tc.policy
+		schema.UnorderedParameters = tc.unordered
+		instance, err := schema.Decode(tc.in)
+		if err != nil {
+			t.Errorf("unexpected error decoding %s: %v", tc.in, err)
+			continue
+		}
+		if len(instance.Extra) != tc.extraLength {
+			t.Errorf("%s: expected %d extra parameters, got %v", tc.in, tc.extraLength, instance.Extra)
+		}
+		encoded, encodeErr := schema.Encode(&instance)
+		if encodeErr != nil || encoded != tc.expected {
+			t.Errorf("expected encoding %s, got %s (error %v)", tc.expected, encoded, encodeErr)
+		}
+		// the encoded string must decode to the same instance
+		again, againErr := schema.Decode(encoded)
+		if againErr != nil || len(again.Extra) != len(instance.Extra) {
+			t.Errorf("round trip of %s failed: %v", encoded, againErr)
+		}
+	}
+
+	schema := *gophc.ScryptPHCSchema
+	schema.UnknownParameters = gophc.PreserveUnknownParameters
+	if _, err := schema.Decode("$scrypt$ln=16,r=8,p=1,x=a_b"); !errors.Is(err, gophc.ErrParameterValueValidation) {
+		t.Errorf("expected ErrParameterValueValidation, got %v", err)
+	}
+	for _, dup := range []string{"$scrypt$ln=16,x=1,r=8,x=2,p=1", "$scrypt$ln=16,r=8,p=1,x=1,x=2"} {
+		if _, err := schema.Decode(dup); !errors.Is(err, gophc.ErrDuplicateParameter) {
+			t.Errorf("expected ErrDuplicateParameter for %s, got %v", dup, err)
+		}
+	}
+	instance := &gophc.PHCInstance{
+		Function:   "scrypt",
+		Parameters: []gophc.ParameterValuePair{{Name: "ln", Value: "4", IsSet: true}, {Name: "r", Value: "8", IsSet: true}, {Name: "p", Value: "1", IsSet: true}},
+		Extra:      []gophc.ParameterValuePair{{Name: "r", Value: "2", IsSet: true}},
+	}
+	if _, err := schema.Encode(instance); !errors.Is(err, gophc.ErrDuplicateParameter) {
+		t.Errorf("expected ErrDuplicateParameter, got %v", err)
+	}
+}
+
+func TestParameterOptions(t *testing.T) {
+	h, err := (&gophc.ScryptPHC{Cost: 16, BlockSize: 8, Parallelism: 1}).HashPassword([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := h.Encode()
+	unordered := strings.Replace(encoded, "ln=4,r=8,p=1", "p=1,ln=4,r=8", 1)
+	options := gophc.ParameterOptions{UnorderedParameters: true}
+
+	if _, err := gophc.DecodeScrypt(unordered); !errors.Is(err, gophc.ErrNonOptionalParameterMissing) {
+		t.Errorf("expected DecodeScrypt to fail with ErrNonOptionalParameterMissing, got %v", err)
+	}
+	decoded, decodeErr := gophc.DecodeScryptWithOptions(unordered, options)
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if again, _ := decoded.Encode(); again != encoded {
+		t.Errorf("expected encoding %s, got %s", encoded, again)
+	}
+
+	registry := gophc.NewDefaultRegistry()
+	registry.ParameterOptions = options
+	if ok, verifyErr := registry.Verify(unordered, []byte("password")); verifyErr != nil || !ok {
+		t.Errorf("expected password to match %s, got %v", unordered, verifyErr)
+	}
+	if _, err := gophc.DefaultRegistry.Decode(unordered); err == nil {
+		t.Errorf("expected the default registry to reject %s", unordered)
+	}
+	argon2 := "$argon2id$v=19$t=2,m=65536,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	if _, err := gophc.DecodeArgon2WithOptions(argon2, options); err != nil {
+		t.Errorf("unexpected error decoding %s: %v", argon2, err)
+	}
+}
+
+func TestTypedExtraParameters(t *testing.T) {
+	options := gophc.ParameterOptions{UnknownParameters: gophc.PreserveUnknownParameters}
+	templates := []gophc.PasswordHasher{
+		&gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1},
+		&gophc.ScryptPHC{Cost: 16, BlockSize: 8, Parallelism: 1},
+	}
+	for _, template := range templates {
+		h, err := template.NewHash([]byte("password"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, _ := h.Encode()
+		// add an unknown parameter after the described ones
+		split := strings.Split(encoded, "$")
+		split[len(split)-3] += ",future=1"
+		withExtra := strings.Join(split, "$")
+
+		var decoded gophc.PasswordHash
+		var decodeErr error
+		var extra []gophc.ParameterValuePair
+		switch template.(type) {
+		case *gophc.Argon2PHC:
+			var phc *gophc.Argon2PHC
+			phc, decodeErr = gophc.DecodeArgon2WithOptions(withExtra, options)
+			if decodeErr == nil {
+				decoded, extra = phc, phc.Extra
+			}
+		case *gophc.ScryptPHC:
+			var phc *gophc.ScryptPHC
+			phc, decodeErr = gophc.DecodeScryptWithOptions(withExtra, options)
+			if decodeErr == nil {
+				decoded, extra = phc, phc.Extra
+			}
+		}
+		if decodeErr != nil {
+			t.Errorf("unexpected error decoding %s: %v", withExtra, decodeErr)
+			continue
+		}
+		if len(extra) != 1 || extra[0].Name != "future" || extra[0].Value != "1" {
+			t.Errorf("%s: expected extra parameter future=1, got %v", withExtra, extra)
+		}
+		if again, encodeErr := decoded.Encode(); encodeErr != nil || again != withExtra {
+			t.Errorf("expected encoding %s, got %s (error %v)", withExtra, again, encodeErr)
+		}
+		if ok, verifyErr := decoded.Verify([]byte("password")); verifyErr != nil || !ok {
+			t.Errorf("expected password to match %s, got %v", withExtra, verifyErr)
+		}
+	}
+}
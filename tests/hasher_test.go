@@ -0,0 +1,91 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestHasher(t *testing.T) {
+	configs := []string{
+		"$argon2id$v=19$m=64,t=1,p=1",
+		"$scrypt$ln=4,r=8,p=1",
+	}
+	for _, config := range configs {
+		hasher, err := gophc.NewHasher(config)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", config, err)
+			continue
+		}
+		first, firstErr := hasher.Hash([]byte("password"))
+		second, secondErr := hasher.Hash([]byte("password"))
+		if firstErr != nil || secondErr != nil {
+			t.Errorf("unexpected errors %v and %v", firstErr, secondErr)
+			continue
+		}
+		if first == second || !strings.HasPrefix(first, config+"$") {
+			t.Errorf("expected new salts with the parameters of %s, got %s and %s", config, first, second)
+		}
+		if ok, verifyErr := gophc.DefaultRegistry.Verify(first, []byte("password")); verifyErr != nil || !ok {
+			t.Errorf("expected password to match %s, got %v", first, verifyErr)
+		}
+	}
+}
+
+func TestHasherInvalid(t *testing.T) {
+	tests := []struct {
+		config   string
+		expected error
+	}{
+		{"$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ", gophc.ErrInvalidTemplate},
+		{"$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG", gophc.ErrInvalidTemplate},
+		{"$argon2id$v=19$m=64,t=0,p=1", nil},
+		{"$argon2id$v=19$m=4194304,t=1,p=1", gophc.ErrResourceLimitExceeded},
+		{"$unknown$x=1", gophc.ErrUnknownFunction},
+		{"$argon2id$v=19$m=64,t=1,p=1,keyid=a2V5", gophc.ErrMissingSecret},
+	}
+	for _, tc := range tests {
+		_, err := gophc.NewHasher(tc.config)
+		var phcErr *gophc.PHCError
+		if err == nil || (tc.expected == nil && !errors.As(err, &phcErr)) || (tc.expected != nil && !errors.Is(err, tc.expected)) {
+			t.Errorf("%s: expected error %v, got %v", tc.config, tc.expected, err)
+		}
+	}
+}
+
+func TestHasherRegistryLimits(t *testing.T) {
+	// t exceeds the DefaultLimits, the hasher must enforce the limits of its registry
+	const config = "$argon2id$v=19$m=64,t=100,p=1"
+	registry := gophc.NewDefaultRegistry()
+	registry.Limits = &gophc.Limits{Argon2: gophc.Argon2Limits{MaxIterations: 1000}}
+	hasher, err := registry.NewHasher(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, hashErr := hasher.Hash([]byte("password"))
+	if hashErr != nil {
+		t.Fatalf("expected the limits of the registry to be used, got %v", hashErr)
+	}
+	if ok, verifyErr := registry.Verify(encoded, []byte("password")); verifyErr != nil || !ok {
+		t.Errorf("expected password to match %s, got %v", encoded, verifyErr)
+	}
+	if _, err := gophc.NewHasher(config); !errors.Is(err, gophc.ErrResourceLimitExceeded) {
+		t.Errorf("expected resource limit error with the default registry, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+var identifyTests = []struct {
+	in         string
+	format     string
+	confidence gophc.Confidence
+	// password is empty if the format has no decoder
+	password string
+}{
+	{"$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA", "argon2i", gophc.ConfidenceHigh, "password"},
+	{"$scrypt$ln=16,r=8,p=1$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E", "scrypt", gophc.ConfidenceHigh, ""},
+	{"$2a$10$dXJ3SW6G7P50lGmMkkmwe.20cQQubK3.HZWzG3YB1tlRy.fqvM/BG", "bcrypt", gophc.ConfidenceHigh, "password"},
+	{"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", "sha512-crypt", gophc.ConfidenceHigh, "Hello world!"},
+	{"$1$saltsalt$qjXMvbEw8oaL.CzflDugX/", "md5-crypt", gophc.ConfidenceMedium, ""},
+	{"$pbkdf2-sha256$29000$c2FsdHNhbHRzYWx0c2FsdA$7kXq22/KjgTkhh6ZQNPiUUP1LIvY67fBNzx94APPyrU", "passlib-pbkdf2-sha256", gophc.ConfidenceHigh, "secret"},
+	{"pbkdf2_sha256$1000$abcdefgh$us6FnqbchHSSQ9YKMkyxsDZqxZS2OCDaNa8Q9imGEZs=", "django-pbkdf2-sha256", gophc.ConfidenceHigh, "pw"},
+	{"bcrypt$$2a$10$dXJ3SW6G7P50lGmMkkmwe.20cQQubK3.HZWzG3YB1tlRy.fqvM/BG", "django-bcrypt", gophc.ConfidenceHigh, "password"},
+	{"{SSHA}lHFzXul4wnzRItssVcTnvXWRjNgBAgMEBQYHCA==", "ldap-ssha", gophc.ConfidenceHigh, "secret"},
+	{"{bcrypt}$2a$10$dXJ3SW6G7P50lGmMkkmwe.20cQQubK3.HZWzG3YB1tlRy.fqvM/BG", "spring-bcrypt", gophc.ConfidenceHigh, "password"},
+	{"8fe4c11451281c094a6578e6ddbf5eed", "md5", gophc.ConfidenceMedium, "pw"},
+}
+
+func TestIdentify(t *testing.T) {
+	for _, tc := range identifyTests {
+		res := gophc.Identify(tc.in)
+		if len(res) == 0 {
+			t.Errorf("no format identified for \"%s\"", tc.in)
+			continue
+		}
+		first := res[0]
+		if first.Format != tc.format || first.Confidence != tc.confidence {
+			t.Errorf("expected format %s with confidence %s for \"%s\", got %s with confidence %s",
+				tc.format, tc.confidence, tc.in, first.Format, first.Confidence)
+			continue
+		}
+		if tc.password == "" {
+			continue
+		}
+		h, err := first.Decoder(tc.in)
+		if err != nil {
+			t.Errorf("unexpected error decoding \"%s\": %v", tc.in, err)
+			continue
+		}
+		if ok, verifyErr := h.Verify([]byte(tc.password)); verifyErr != nil || !ok {
+			t.Errorf("expected password to match \"%s\", got %v (error %v)", tc.in, ok, verifyErr)
+		}
+	}
+}
+
+func TestIdentifyUnknown(t *testing.T) {
+	for _, in := range []string{"", "password", "{", "$"} {
+		if res := gophc.Identify(in); len(res) != 0 {
+			t.Errorf("expected no identification for \"%s\", got %v", in, res)
+		}
+	}
+}
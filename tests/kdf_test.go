@@ -0,0 +1,108 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestDeriveKey(t *testing.T) {
+	expected, _ := base64.RawStdEncoding.DecodeString("T/XOJ2mh1/TIpJHfCdQan76Q5esCFVoT5MAeIM1Oq2E")
+	key, err := gophc.DeriveKey("$argon2i$v=19$m=256,t=2,p=2$c29tZXNhbHQ", []byte("password"), len(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, expected) {
+		t.Errorf("expected key %x, got %x", expected, key)
+	}
+
+	h, hashErr := (&gophc.ScryptPHC{Cost: 16, BlockSize: 8, Parallelism: 1}).HashPassword([]byte("passphrase"))
+	if hashErr != nil {
+		t.Fatal(hashErr)
+	}
+	config := "$scrypt$ln=4,r=8,p=1$" + h.SaltString
+	scryptKey, scryptErr := gophc.DeriveKey(config, []byte("passphrase"), len(h.Hash))
+	if scryptErr != nil {
+		t.Fatal(scryptErr)
+	}
+	if !bytes.Equal(scryptKey, h.Hash) {
+		t.Errorf("expected key %x, got %x", h.Hash, scryptKey)
+	}
+
+	if _, err := gophc.DeriveKey("$argon2i$v=19$m=256,t=2,p=2", []byte("password"), 32); !errors.Is(err, gophc.ErrMissingSalt) {
+		t.Errorf("expected ErrMissingSalt, got %v", err)
+	}
+	if _, err := gophc.DeriveKey("$argon2i$v=19$m=256,t=2,p=2$c29tZXNhbHQ", []byte("password"), 2); !errors.Is(err, gophc.ErrInvalidKeyLength) {
+		t.Errorf("expected ErrInvalidKeyLength, got %v", err)
+	}
+	if _, err := gophc.DeriveKey("$2a$04$2ZnX8K5.w8ANYaEODaOi4O8U5hC1g/bFlqB1.gwnIqRNm7QuS8AIe", []byte("password"), 32); !errors.Is(err, gophc.ErrKDFNotSupported) {
+		t.Errorf("expected ErrKDFNotSupported, got %v", err)
+	}
+}
+
+func TestDeriveKeyLength(t *testing.T) {
+	const config = "$scrypt$ln=4,r=8,p=1$c29tZXNhbHQ"
+	if _, err := gophc.DeriveKey(config, []byte("passphrase"), 1<<30); !errors.Is(err, gophc.ErrResourceLimitExceeded) {
+		t.Errorf("expected resource limit error, got %v", err)
+	}
+	registry := gophc.NewDefaultRegistry()
+	registry.Limits = &gophc.Limits{MaxHashLength: 16}
+	if _, err := registry.DeriveKeyContext(context.Background(), config, []byte("passphrase"), 32); !errors.Is(err, gophc.ErrResourceLimitExceeded) {
+		t.Errorf("expected resource limit error with registry limits, got %v", err)
+	}
+}
+
+func TestDeriveKeyWithSecret(t *testing.T) {
+	template := &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1, KeyID: []byte("k1")}
+	secret := []byte("argon2 secret")
+	expected, err := template.HashPasswordWithSecret([]byte("passphrase"), secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := *expected
+	config.Hash = nil
+	if _, err := config.DeriveKey([]byte("passphrase"), 32); !errors.Is(err, gophc.ErrMissingSecret) {
+		t.Errorf("expected ErrMissingSecret for a hash with keyid, got %v", err)
+	}
+	key, keyErr := config.DeriveKeyWithSecret([]byte("passphrase"), secret, len(expected.Hash))
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+	if !bytes.Equal(key, expected.Hash) {
+		t.Errorf("expected key %x, got %x", expected.Hash, key)
+	}
+}
+
+func TestNewKDFConfig(t *testing.T) {
+	config, err := gophc.NewKDFConfig(&gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(config, "$argon2id$v=19$m=64,t=1,p=1$") || strings.Count(config, "$") != 4 {
+		t.Errorf("unexpected config string %s", config)
+	}
+	first, firstErr := gophc.DeriveKey(config, []byte("passphrase"), 32)
+	second, secondErr := gophc.DeriveKey(config, []byte("passphrase"), 32)
+	if firstErr != nil || secondErr != nil || !bytes.Equal(first, second) {
+		t.Errorf("expected equal keys, got errors %v and %v", firstErr, secondErr)
+	}
+}
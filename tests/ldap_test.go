@@ -0,0 +1,123 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+var ldapTests = []struct {
+	in       string
+	password string
+}{
+	{"{SSHA}lHFzXul4wnzRItssVcTnvXWRjNgBAgMEBQYHCA==", "secret"},
+	{"{SSHA512}KO8EsMPQTwZrxxbOkDAOOXEeVCc2grMQg1pnZwZhC1bBQLby8zCmFn7qTZRvoTd+yQdROQQNYHWpTUST4zjTdQECAwQFBgcI", "secret"},
+	{"{MD5}Xr4ilOzQ4PCOq3aQ0qbuaQ==", "secret"},
+	{"{PBKDF2-SHA256}29000$c2FsdHNhbHRzYWx0c2FsdA$7kXq22/KjgTkhh6ZQNPiUUP1LIvY67fBNzx94APPyrU", "secret"},
+	{"{CRYPT}$6$abcdefgh$ltjgWl6579NluT/Vi1nwEvcil.G5Nbc4NiXZaNGStk8PSwGfQv72N2CKPPrVACtLtip/cZ/1GM/O6IND4WQhG.", "secret"},
+	{"{crypt}$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5", "Hello world!"},
+	{"{ARGON2}$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA", "password"},
+}
+
+func TestLDAPDecodeVerify(t *testing.T) {
+	for _, tc := range ldapTests {
+		h, err := gophc.DecodeLDAP(tc.in)
+		if err != nil {
+			t.Errorf("unexpected error decoding \"%s\": %v", tc.in, err)
+			continue
+		}
+		ok, verifyErr := h.Verify([]byte(tc.password))
+		if verifyErr != nil || !ok {
+			t.Errorf("expected password to match \"%s\", got %v (error %v)", tc.in, ok, verifyErr)
+		}
+		ok, verifyErr = h.Verify([]byte(tc.password + "x"))
+		if verifyErr != nil || ok {
+			t.Errorf("expected wrong password not to match \"%s\", got %v (error %v)", tc.in, ok, verifyErr)
+		}
+		encoded, encodeErr := h.Encode()
+		if encodeErr != nil {
+			t.Errorf("unexpected error encoding \"%s\": %v", tc.in, encodeErr)
+			continue
+		}
+		if encoded != tc.in {
+			t.Errorf("expected encoding \"%s\", got \"%s\"", tc.in, encoded)
+		}
+	}
+}
+
+func TestLDAPInvalid(t *testing.T) {
+	if _, err := gophc.DecodeLDAP("{CLEARTEXT}secret"); !errors.Is(err, gophc.ErrUnknownLDAPScheme) {
+		t.Errorf("expected ErrUnknownLDAPScheme, got %v", err)
+	}
+	if _, err := gophc.DecodeLDAP("{SHA}AAAA"); !errors.Is(err, gophc.ErrInvalidLDAPHash) {
+		t.Errorf("expected ErrInvalidLDAPHash, got %v", err)
+	}
+}
+
+// test vectors from https://www.akkadia.org/drepper/SHA-crypt.txt
+var shaCryptTests = []struct {
+	in       string
+	password string
+}{
+	{"$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5", "Hello world!"},
+	{"$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA", "Hello world!"},
+	{"$5$rounds=5000$toolongsaltstrin$Un/5jzAHMgOGZ5.mWJpuVolil07guHPvOW8mGRcvxa5", "This is just a test"},
+	{"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", "Hello world!"},
+	{"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.", "Hello world!"},
+	{"$6$rounds=5000$toolongsaltstrin$lQ8jolhgVRVhY4b5pZKaysCLi0QBxGoNeKQzQ3glMhwllF7oGDZxUhx1yxdYcz/e1JSbq3y6JMxxl8audkUEm0", "This is just a test"},
+}
+
+func TestSHACrypt(t *testing.T) {
+	for _, tc := range shaCryptTests {
+		ok, err := gophc.DefaultRegistry.Verify(tc.in, []byte(tc.password))
+		if err != nil || !ok {
+			t.Errorf("expected password to match \"%s\", got %v (error %v)", tc.in, ok, err)
+		}
+	}
+}
+
+func TestSHACryptClamp(t *testing.T) {
+	// test vectors from the specification, the salt is truncated to 16 characters and the rounds are clamped
+	tests := []struct {
+		in       string
+		password string
+		encoded  string
+	}{
+		{"$5$rounds=10000$saltstringsaltstring$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA", "Hello world!",
+			"$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA"},
+		{"$5$rounds=5000$toolongsaltstring$Un/5jzAHMgOGZ5.mWJpuVolil07guHPvOW8mGRcvxa5", "This is just a test",
+			"$5$rounds=5000$toolongsaltstrin$Un/5jzAHMgOGZ5.mWJpuVolil07guHPvOW8mGRcvxa5"},
+		{"$5$rounds=10$roundstoolow$yfvwcWrQ8l/K0DAWyuPMDNHpIVlTQebY9l/gL972bIC", "the minimum number is still observed",
+			"$5$rounds=1000$roundstoolow$yfvwcWrQ8l/K0DAWyuPMDNHpIVlTQebY9l/gL972bIC"},
+		{"$5$rounds=0$roundstoolow$yfvwcWrQ8l/K0DAWyuPMDNHpIVlTQebY9l/gL972bIC", "the minimum number is still observed",
+			"$5$rounds=1000$roundstoolow$yfvwcWrQ8l/K0DAWyuPMDNHpIVlTQebY9l/gL972bIC"},
+	}
+	for _, tc := range tests {
+		h, err := gophc.DecodeSHACrypt(tc.in)
+		if err != nil {
+			t.Errorf("unexpected error decoding %s: %v", tc.in, err)
+			continue
+		}
+		if ok, verifyErr := h.Verify([]byte(tc.password)); verifyErr != nil || !ok {
+			t.Errorf("expected password to match \"%s\", got %v (error %v)", tc.in, ok, verifyErr)
+		}
+		if encoded, encodeErr := h.Encode(); encodeErr != nil || encoded != tc.encoded {
+			t.Errorf("expected encoding %s, got %s (error %v)", tc.encoded, encoded, encodeErr)
+		}
+	}
+}
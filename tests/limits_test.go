@@ -0,0 +1,97 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestLimitsDecode(t *testing.T) {
+	tests := []string{
+		"$argon2id$v=19$m=4294967295,t=2,p=1$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG",
+		"$argon2id$v=19$m=65536,t=1000000,p=1$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG",
+		"$scrypt$ln=62,r=8,p=1$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E",
+		"$pbkdf2-sha256$i=2000000000$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG",
+		"$2a$31$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		"$scrypt$" + strings.Repeat("a", 5000),
+	}
+	for _, s := range tests {
+		_, err := gophc.DefaultRegistry.Decode(s)
+		if !errors.Is(err, gophc.ErrResourceLimitExceeded) {
+			t.Errorf("expected resource limit error for %q, got %v", s, err)
+		}
+	}
+}
+
+func TestLimitsVerify(t *testing.T) {
+	phc := &gophc.Argon2PHC{
+		Variant: "argon2id",
+		Version: 0x13,
+		M:       1 << 30,
+		T:       1,
+		P:       1,
+		Salt:    []byte("somesaltsomesalt"),
+		Hash:    make([]byte, 32),
+	}
+	_, err := phc.Verify([]byte("password"))
+	if !errors.Is(err, gophc.ErrResourceLimitExceeded) {
+		t.Errorf("expected resource limit error, got %v", err)
+	}
+}
+
+func TestLimitsNil(t *testing.T) {
+	var limits *gophc.Limits
+	phc := &gophc.ScryptPHC{Cost: 1 << 30, BlockSize: 8, Parallelism: 1}
+	if err := limits.CheckScrypt(phc); err != nil {
+		t.Errorf("nil limits must not restrict anything, got %v", err)
+	}
+	err := gophc.DefaultLimits.CheckScrypt(phc)
+	if !errors.Is(err, gophc.ErrResourceLimitExceeded) {
+		t.Errorf("expected resource limit error, got %v", err)
+	} else if !strings.Contains(err.Error(), `parameter "N"`) {
+		t.Errorf("expected the cost to be reported as N, got %v", err)
+	}
+}
+
+func TestLimitsRegistry(t *testing.T) {
+	const argon2Hash = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	strict := gophc.NewDefaultRegistry()
+	strict.Limits = &gophc.Limits{Argon2: gophc.Argon2Limits{MaxIterations: 1}}
+	if _, err := strict.Decode(argon2Hash); !errors.Is(err, gophc.ErrResourceLimitExceeded) {
+		t.Errorf("expected resource limit error, got %v", err)
+	}
+	decoder, _ := strict.Decoder("argon2id")
+	if _, err := decoder(argon2Hash); !errors.Is(err, gophc.ErrResourceLimitExceeded) {
+		t.Errorf("expected resource limit error from Decoder, got %v", err)
+	}
+	if _, err := gophc.DefaultRegistry.Decode(argon2Hash); err != nil {
+		t.Errorf("unexpected error with default limits: %v", err)
+	}
+
+	// t exceeds the DefaultLimits, but a registry without limits decodes and verifies it
+	const manyIterations = "$argon2id$v=19$m=8,t=100,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	unlimited := gophc.NewDefaultRegistry()
+	unlimited.Limits = &gophc.Limits{}
+	if _, err := gophc.DefaultRegistry.Decode(manyIterations); !errors.Is(err, gophc.ErrResourceLimitExceeded) {
+		t.Errorf("expected resource limit error with default limits, got %v", err)
+	}
+	if ok, err := unlimited.Verify(manyIterations, []byte("password")); err != nil || ok {
+		t.Errorf("expected (false, nil) without limits, got (%v, %v)", ok, err)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+const (
+	composedPassword   = "caf\u00e9"
+	decomposedPassword = "cafe\u0301"
+)
+
+func TestNormalizePassword(t *testing.T) {
+	tests := []struct {
+		profile  string
+		in       string
+		expected string
+	}{
+		{gophc.NormalizationNone, decomposedPassword, decomposedPassword},
+		{gophc.NormalizationNFC, decomposedPassword, composedPassword},
+		{gophc.NormalizationNFKC, "\ufb01le", "file"},
+		{gophc.NormalizationOpaqueString, decomposedPassword, composedPassword},
+		{gophc.NormalizationOpaqueString, "pass word", "pass word"},
+	}
+	for _, tc := range tests {
+		got, err := gophc.NormalizePassword(tc.profile, []byte(tc.in))
+		if err != nil {
+			t.Errorf("profile %q: unexpected error %v", tc.profile, err)
+			continue
+		}
+		if string(got) != tc.expected {
+			t.Errorf("profile %q: expected %q, got %q", tc.profile, tc.expected, got)
+		}
+	}
+	for _, in := range []string{"", "pass\x07word", "\xff"} {
+		if _, err := gophc.NormalizePassword(gophc.NormalizationOpaqueString, []byte(in)); !errors.Is(err, gophc.ErrNormalization) {
+			t.Errorf("expected ErrNormalization for %q, got %v", in, err)
+		}
+	}
+	if _, err := gophc.NormalizePassword("nfd", []byte("x")); !errors.Is(err, gophc.ErrUnknownNormalization) {
+		t.Errorf("expected ErrUnknownNormalization, got %v", err)
+	}
+}
+
+func TestNormalizedHashes(t *testing.T) {
+	templates := []gophc.PasswordHasher{
+		&gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1, Normalization: gophc.NormalizationNFC},
+		&gophc.ScryptPHC{Cost: 16, BlockSize: 8, Parallelism: 1, Normalization: gophc.NormalizationOpaqueString},
+	}
+	for _, template := range templates {
+		h, err := template.NewHash([]byte(composedPassword))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, encodeErr := h.Encode()
+		if encodeErr != nil {
+			t.Fatal(encodeErr)
+		}
+		if !strings.Contains(encoded, ",norm=") {
+			t.Errorf("expected norm parameter in %s", encoded)
+		}
+		ok, verifyErr := gophc.DefaultRegistry.Verify(encoded, []byte(decomposedPassword))
+		if verifyErr != nil || !ok {
+			t.Errorf("expected decomposed password to verify against %s, got %v", encoded, verifyErr)
+		}
+		if ok, _ := gophc.DefaultRegistry.Verify(encoded, []byte("cafe")); ok {
+			t.Errorf("wrong password verified against %s", encoded)
+		}
+	}
+	if _, err := gophc.DecodeArgon2("$argon2id$v=19$m=64,t=1,p=1,norm=nfd$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG"); !errors.Is(err, gophc.ErrUnknownNormalization) {
+		t.Errorf("expected ErrUnknownNormalization, got %v", err)
+	}
+}
+
+func TestNormalizedPepper(t *testing.T) {
+	ring, err := gophc.NewStaticKeyRing([]byte("k1"), map[string][]byte{"k1": []byte("pepper")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pepper := gophc.NewPepper(ring, nil)
+	template := &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1,
+		Normalization: gophc.NormalizationOpaqueString}
+	h, hashErr := pepper.NewHash(template, []byte(decomposedPassword))
+	if hashErr != nil {
+		t.Fatal(hashErr)
+	}
+	encoded, _ := h.Encode()
+	ok, verifyErr := pepper.Verify(encoded, []byte(composedPassword))
+	if verifyErr != nil || !ok {
+		t.Errorf("expected composed password to verify, got %v", verifyErr)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+var onionTemplate = &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1}
+
+func TestOnionHexDigest(t *testing.T) {
+	// md5("password")
+	legacy := []string{"5f4dcc3b5aa765d61d8327deb882cf99", "not hex"}
+	res := gophc.ConvertLegacyHashes(onionTemplate, gophc.DecodeHexDigest("md5"), legacy)
+	if res[1].Err == nil {
+		t.Error("expected an error for invalid input")
+	}
+	if res[0].Err != nil {
+		t.Fatal(res[0].Err)
+	}
+	onion := res[0].Onion
+	if !strings.HasPrefix(onion, "$onion-md5$v=19$m=64,t=1,p=1$") {
+		t.Errorf("unexpected onion hash %s", onion)
+	}
+	ok, err := gophc.DefaultRegistry.Verify(onion, []byte("password"))
+	if err != nil || !ok {
+		t.Errorf("expected %s to verify, got %v", onion, err)
+	}
+	if ok, _ := gophc.DefaultRegistry.Verify(onion, []byte("wrong")); ok {
+		t.Errorf("%s verifies wrong password", onion)
+	}
+	decoded, _ := gophc.DefaultRegistry.Decode(onion)
+	if !gophc.NeedsRehash(decoded, onionTemplate) {
+		t.Error("onion hashes must always need a rehash")
+	}
+}
+
+func TestOnionLDAPAndPBKDF2(t *testing.T) {
+	legacy := []string{
+		// {SSHA} of "password" with salt "salt"
+		"{SSHA}yI6cZwQadOA1e+/f+T+H3eCQQhRzYWx0",
+		// PBKDF2-SHA256 of "password" with salt "salt" and 1000 iterations
+		"$pbkdf2-sha256$i=1000$c2FsdA$YywoEuRtRgQQK6dhjp1tfS+BKPYma0oDJk0qBGC33LM",
+	}
+	decoder := func(s string) (gophc.PasswordHash, error) {
+		if strings.HasPrefix(s, "{") {
+			return gophc.DecodeLDAP(s)
+		}
+		return gophc.DefaultRegistry.Decode(s)
+	}
+	for _, conversion := range gophc.ConvertLegacyHashes(onionTemplate, decoder, legacy) {
+		if conversion.Err != nil {
+			t.Errorf("can't convert %s: %v", conversion.Legacy, conversion.Err)
+			continue
+		}
+		decoded, err := gophc.DecodeOnion(conversion.Onion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, _ := decoded.Encode()
+		if encoded != conversion.Onion {
+			t.Errorf("round trip failed: %s != %s", encoded, conversion.Onion)
+		}
+		ok, verifyErr := decoded.Verify([]byte("password"))
+		if verifyErr != nil || !ok {
+			t.Errorf("expected %s to verify, got %v", conversion.Onion, verifyErr)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	h, err := onionTemplate.HashPassword([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gophc.NeedsRehash(h, onionTemplate) {
+		t.Error("hash with template parameters doesn't need a rehash")
+	}
+	if !gophc.NeedsRehash(h, gophc.Argon2OWASP()) {
+		t.Error("expected rehash for other parameters")
+	}
+	if !gophc.NeedsRehash(h, gophc.ScryptOWASP()) {
+		t.Error("expected rehash for other algorithm")
+	}
+}
+
+func TestOnionTemplateAssociatedData(t *testing.T) {
+	// md5("password")
+	legacy, err := gophc.DecodeLDAP("{MD5}X03MO1qnZdYdgyfeuILPmQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := *onionTemplate
+	template.Data = []byte("ad")
+	template.KeyID = []byte("key")
+	template.PepperID = []byte("pid")
+	onion, err := gophc.WrapLegacyHash(&template, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, encodeErr := onion.Encode()
+	if encodeErr != nil {
+		t.Fatal(encodeErr)
+	}
+	decoded, decodeErr := gophc.DecodeOnion(encoded)
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if ok, verifyErr := decoded.Verify([]byte("password")); verifyErr != nil || !ok {
+		t.Errorf("expected %s to verify, got %v", encoded, verifyErr)
+	}
+
+	decoded.Outer.Data = []byte("ad")
+	if _, err := decoded.Encode(); err == nil {
+		t.Error("expected an error for an outer hash with data")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestPasswordRedacted(t *testing.T) {
+	password := gophc.NewPasswordString("hunter2")
+	outputs := []string{
+		fmt.Sprint(password),
+		fmt.Sprintf("%v %+v %#v %s %q %x", password, password, password, password, password, password),
+		fmt.Sprintf("%v", *password),
+		fmt.Sprintf("%+v", struct{ P *gophc.Password }{password}),
+	}
+	encoded, err := json.Marshal(struct{ P *gophc.Password }{password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs = append(outputs, string(encoded))
+	for _, output := range outputs {
+		if strings.Contains(output, "hunter2") || strings.Contains(output, "68756e74657232") {
+			t.Errorf("password leaked in %q", output)
+		}
+	}
+}
+
+func TestPasswordWipe(t *testing.T) {
+	raw := []byte("password")
+	password := gophc.NewPassword(raw)
+	template := &gophc.ScryptPHC{Cost: 16, BlockSize: 8, Parallelism: 1}
+	h, err := gophc.NewHashPassword(context.Background(), template, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := h.Encode()
+	ok, verifyErr := gophc.DefaultRegistry.VerifyPassword(context.Background(), encoded, password)
+	if verifyErr != nil || !ok {
+		t.Errorf("expected password to verify, got %v", verifyErr)
+	}
+	b, _ := password.Bytes()
+	password.Wipe()
+	for _, c := range b {
+		if c != 0 {
+			t.Fatal("password was not wiped")
+		}
+	}
+	if string(raw) != "password" {
+		t.Error("NewPassword must copy its input")
+	}
+	if _, err := gophc.DefaultRegistry.VerifyPassword(context.Background(), encoded, password); !errors.Is(err, gophc.ErrPasswordWiped) {
+		t.Errorf("expected wiped error, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestPepperRotation(t *testing.T) {
+	oldRing, err := gophc.NewStaticKeyRing([]byte("k1"), map[string][]byte{"k1": []byte("first secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates := []gophc.PasswordHasher{
+		&gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1},
+		&gophc.ScryptPHC{Cost: 1 << 10, BlockSize: 8, Parallelism: 1},
+	}
+	for _, template := range templates {
+		pepper := gophc.NewPepper(oldRing, nil)
+		h, hashErr := pepper.NewHash(template, []byte("password"))
+		if hashErr != nil {
+			t.Fatal(hashErr)
+		}
+		encoded, _ := h.Encode()
+		if !strings.Contains(encoded, "pid=azE") {
+			t.Errorf("expected pid in %s", encoded)
+		}
+		// without the pepper the hash doesn't verify
+		if ok, _ := gophc.DefaultRegistry.Verify(encoded, []byte("password")); ok {
+			t.Errorf("hash %s verifies without pepper", encoded)
+		}
+		if ok, verifyErr := pepper.Verify(encoded, []byte("password")); verifyErr != nil || !ok {
+			t.Errorf("expected %s to verify, got %v", encoded, verifyErr)
+		}
+
+		newRing, _ := gophc.NewStaticKeyRing([]byte("k2"), map[string][]byte{
+			"k1": []byte("first secret"),
+			"k2": []byte("second secret"),
+		})
+		rotated := gophc.NewPepper(newRing, nil)
+		decoded, _ := gophc.DefaultRegistry.Decode(encoded)
+		if !rotated.NeedsRehash(decoded) {
+			t.Errorf("expected %s to need a rehash", encoded)
+		}
+		if ok, verifyErr := rotated.Verify(encoded, []byte("password")); verifyErr != nil || !ok {
+			t.Errorf("expected %s to verify after rotation, got %v", encoded, verifyErr)
+		}
+
+		onlyNew, _ := gophc.NewStaticKeyRing([]byte("k2"), map[string][]byte{"k2": []byte("second secret")})
+		if _, verifyErr := gophc.NewPepper(onlyNew, nil).Verify(encoded, []byte("password")); !errors.Is(verifyErr, gophc.ErrUnknownPepper) {
+			t.Errorf("expected unknown pepper error, got %v", verifyErr)
+		}
+	}
+}
+
+func TestPepperUnpepperedHash(t *testing.T) {
+	ring, _ := gophc.NewStaticKeyRing([]byte("k1"), map[string][]byte{"k1": []byte("secret")})
+	pepper := gophc.NewPepper(ring, nil)
+	template := &gophc.ScryptPHC{Cost: 1 << 10, BlockSize: 8, Parallelism: 1}
+	h, err := template.HashPassword([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, verifyErr := pepper.VerifyHash(context.Background(), h, []byte("password")); verifyErr != nil || !ok {
+		t.Errorf("expected hash without pid to verify, got %v", verifyErr)
+	}
+	if !pepper.NeedsRehash(h) {
+		t.Error("expected hash without pepper to need a rehash")
+	}
+}
+
+func TestPepperKeepsArgon2KeyID(t *testing.T) {
+	ring, _ := gophc.NewStaticKeyRing([]byte("k1"), map[string][]byte{"k1": []byte("secret")})
+	pepper := gophc.NewPepper(ring, nil)
+	// keyid identifies the argon2 secret key, it is independent of the pepper
+	template := &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1, KeyID: []byte("sk")}
+	h, err := pepper.NewHash(template, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := h.Encode()
+	if !strings.Contains(encoded, "keyid=c2s") || !strings.Contains(encoded, "pid=azE") {
+		t.Errorf("expected keyid and pid in %s", encoded)
+	}
+	if ok, verifyErr := pepper.Verify(encoded, []byte("password")); verifyErr != nil || !ok {
+		t.Errorf("expected %s to verify, got %v", encoded, verifyErr)
+	}
+
+	withSecret, secretErr := template.HashPasswordWithSecret([]byte("password"), []byte("argon2 secret"))
+	if secretErr != nil {
+		t.Fatal(secretErr)
+	}
+	if id, _ := gophc.PepperKeyID(withSecret); len(id) != 0 {
+		t.Errorf("keyid must not be used as pepper id, got %q", id)
+	}
+	if !pepper.NeedsRehash(withSecret) {
+		t.Error("expected hash without pid to need a rehash")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+const versionSegmentHash = "CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+func formatParameters(params []gophc.ParameterValuePair) string {
+	res := make([]string, 0, len(params))
+	for _, param := range params {
+		if param.IsSet {
+			res = append(res, param.Name+"="+param.Value)
+		}
+	}
+	return strings.Join(res, ",")
+}
+
+func TestPHCParserVersionSegment(t *testing.T) {
+	parser := gophc.NewPHCParser()
+	tests := []struct {
+		in             string
+		expectedParams string
+	}{
+		{"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$" + versionSegmentHash, "v=19,m=65536,t=2,p=1"},
+		{"$argon2id$m=65536,t=2,p=1$c29tZXNhbHQ$" + versionSegmentHash, "m=65536,t=2,p=1"},
+		// without other parameters the version segment is the parameter list
+		{"$argon2id$v=19$c29tZXNhbHQ", "v=19"},
+	}
+	for _, tc := range tests {
+		instance, err := parser.Parse(tc.in)
+		if err != nil {
+			t.Errorf("unexpected error parsing \"%s\": %v", tc.in, err)
+			continue
+		}
+		if got := formatParameters(instance.Parameters); got != tc.expectedParams {
+			t.Errorf("expected parameters \"%s\" for \"%s\", got \"%s\"", tc.expectedParams, tc.in, got)
+		}
+		if string(instance.Salt) != "somesalt" {
+			t.Errorf("expected salt \"somesalt\" for \"%s\", got \"%s\"", tc.in, instance.Salt)
+		}
+	}
+}
+
+func TestSchemaVersionSegment(t *testing.T) {
+	tests := []string{
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$" + versionSegmentHash,
+		"$argon2id$m=65536,t=2,p=1$c29tZXNhbHQ$" + versionSegmentHash,
+	}
+	for _, in := range tests {
+		instance, err := gophc.Argon2Schema.Decode(in)
+		if err != nil {
+			t.Errorf("unexpected error decoding \"%s\": %v", in, err)
+			continue
+		}
+		encoded, encodeErr := gophc.Argon2Schema.Encode(&instance)
+		if encodeErr != nil || encoded != in {
+			t.Errorf("expected encoding \"%s\", got \"%s\" (error %v)", in, encoded, encodeErr)
+		}
+	}
+	// the version is only allowed as first parameter
+	if _, err := gophc.Argon2Schema.Decode("$argon2id$m=65536,v=19,t=2,p=1$c29tZXNhbHQ"); err == nil {
+		t.Error("expected error for version in the middle of the parameters")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestPresetsValid(t *testing.T) {
+	for _, preset := range gophc.Presets() {
+		var err error
+		switch {
+		case preset.Argon2 != nil:
+			err = preset.Argon2.ValidateParameters()
+			if err == nil {
+				err = gophc.DefaultLimits.CheckArgon2(preset.Argon2)
+			}
+		case preset.Scrypt != nil:
+			err = preset.Scrypt.ValidateParameters()
+			if err == nil {
+				err = gophc.DefaultLimits.CheckScrypt(preset.Scrypt)
+			}
+		default:
+			t.Errorf("preset %s has no parameters", preset.Name)
+		}
+		if err != nil {
+			t.Errorf("preset %s is invalid: %v", preset.Name, err)
+		}
+		if found, ok := gophc.PresetByName(preset.Name); !ok || found.Name != preset.Name {
+			t.Errorf("can't find preset %s by name", preset.Name)
+		}
+	}
+}
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	templates := []gophc.PasswordHasher{
+		&gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 2},
+		&gophc.ScryptPHC{Cost: 1 << 10, BlockSize: 8, Parallelism: 1},
+		gophc.ScryptLibsodiumInteractive(),
+	}
+	for _, template := range templates {
+		h, err := template.NewHash([]byte("password"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, encodeErr := h.Encode()
+		if encodeErr != nil {
+			t.Fatal(encodeErr)
+		}
+		ok, verifyErr := gophc.DefaultRegistry.Verify(encoded, []byte("password"))
+		if verifyErr != nil || !ok {
+			t.Errorf("hash %s doesn't verify: %v", encoded, verifyErr)
+		}
+		ok, _ = gophc.DefaultRegistry.Verify(encoded, []byte("wrong"))
+		if ok {
+			t.Errorf("hash %s verifies wrong password", encoded)
+		}
+	}
+}
+
+func TestPresetCheckMinimum(t *testing.T) {
+	strong := &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 65536, T: 3, P: 4}
+	if err := gophc.Argon2OWASP().CheckMinimum(strong); err != nil {
+		t.Errorf("expected %+v to satisfy OWASP, got %v", strong, err)
+	}
+	weak := []gophc.PasswordHash{
+		&gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 4096, T: 3, P: 4},
+		&gophc.Argon2PHC{Variant: "argon2i", Version: 0x13, M: 65536, T: 3, P: 4},
+		&gophc.Argon2PHC{Variant: "argon2id", Version: 0x10, M: 65536, T: 3, P: 4},
+		&gophc.ScryptPHC{Cost: 1 << 20, BlockSize: 8, Parallelism: 1},
+	}
+	for _, h := range weak {
+		if err := gophc.Argon2OWASP().CheckMinimum(h); !errors.Is(err, gophc.ErrBelowPolicyMinimum) {
+			t.Errorf("expected policy error for %+v, got %v", h, err)
+		}
+	}
+	if err := gophc.ScryptOWASP().CheckMinimum(&gophc.ScryptPHC{Cost: 1 << 14, BlockSize: 8, Parallelism: 1}); !errors.Is(err, gophc.ErrBelowPolicyMinimum) {
+		t.Errorf("expected policy error, got %v", err)
+	}
+}
+
+func TestPresetCopies(t *testing.T) {
+	weakened := gophc.Argon2OWASP()
+	weakened.Argon2.M = 8
+	if gophc.Argon2OWASP().Argon2.M == 8 {
+		t.Error("changing a preset must not change the preset of the package")
+	}
+	found, _ := gophc.PresetByName(weakened.Name)
+	found.Argon2.T = 1
+	if gophc.Argon2OWASP().Argon2.T == 1 {
+		t.Error("changing a preset returned by PresetByName must not change the preset of the package")
+	}
+}
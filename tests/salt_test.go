@@ -0,0 +1,84 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+func TestSaltGenerator(t *testing.T) {
+	generator := gophc.NewSaltGenerator(bytes.NewReader(bytes.Repeat([]byte{0x42}, 64)))
+	generator.SetLength("scrypt", 24)
+	salt, err := generator.Generate("scrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(salt, bytes.Repeat([]byte{0x42}, 24)) {
+		t.Errorf("unexpected salt %x", salt)
+	}
+	if length := generator.Length("argon2id"); length != gophc.DefaultSaltLength {
+		t.Errorf("expected default length %d, got %d", gophc.DefaultSaltLength, length)
+	}
+	// only 40 bytes left
+	if _, err := generator.GenerateN(41); !errors.Is(err, gophc.ErrSaltGeneration) {
+		t.Errorf("expected salt generation error on short read, got %v", err)
+	}
+	if _, err := generator.GenerateN(0); !errors.Is(err, gophc.ErrSaltGeneration) {
+		t.Errorf("expected salt generation error for length 0, got %v", err)
+	}
+}
+
+func TestArgon2ShortTemplateSalt(t *testing.T) {
+	template := &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1, Salt: []byte("short")}
+	if _, err := template.HashPassword([]byte("password")); !errors.Is(err, gophc.ErrParameterValueValidation) {
+		t.Errorf("expected validation error for a 5 byte salt, got %v", err)
+	}
+	generator := gophc.NewSaltGenerator(nil)
+	generator.SetLength("argon2id", 4)
+	old := gophc.DefaultSaltGenerator
+	defer func() { gophc.DefaultSaltGenerator = old }()
+	gophc.DefaultSaltGenerator = generator
+	template.Salt = nil
+	if _, err := template.HashPassword([]byte("password")); !errors.Is(err, gophc.ErrParameterValueValidation) {
+		t.Errorf("expected validation error for a 4 byte generated salt, got %v", err)
+	}
+}
+
+func TestDefaultSaltGeneratorUsed(t *testing.T) {
+	old := gophc.DefaultSaltGenerator
+	defer func() { gophc.DefaultSaltGenerator = old }()
+
+	gophc.DefaultSaltGenerator = gophc.NewSaltGenerator(bytes.NewReader(bytes.Repeat([]byte{0x01}, 32)))
+	template := &gophc.Argon2PHC{Variant: "argon2id", Version: 0x13, M: 64, T: 1, P: 1}
+	first, err := template.HashPassword([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Salt, bytes.Repeat([]byte{0x01}, 16)) {
+		t.Errorf("salt not read from DefaultSaltGenerator: %x", first.Salt)
+	}
+	// 16 bytes left, the second hash succeeds, the third one must fail
+	if _, err := template.HashPassword([]byte("password")); err != nil {
+		t.Fatal(err)
+	}
+	scryptTemplate := &gophc.ScryptPHC{Cost: 1 << 10, BlockSize: 8, Parallelism: 1}
+	if _, err := scryptTemplate.HashPassword([]byte("password")); !errors.Is(err, gophc.ErrSaltGeneration) {
+		t.Errorf("expected salt generation error, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Fabian Wenzelmann <fabianwen@posteo.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/FabianWe/gophc"
+)
+
+// examples from the documentation of Spring's DelegatingPasswordEncoder and the argon2 reference implementation,
+// all for the password "password"
+var springTests = []string{
+	"{argon2}$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA",
+	"{bcrypt}$2a$10$dXJ3SW6G7P50lGmMkkmwe.20cQQubK3.HZWzG3YB1tlRy.fqvM/BG",
+	"{scrypt}$e0801$8bWJaSu2IKSn9Z9kM+TPXfOc/9bdYSrN1oD9qfVThWEwdRTnO7re7Ei+fUZRJ68k9lTyuTeUp4of4g24hHnazw==$OAOec05+bXxvuu/1qZ6NUR+xQYvYv7BeL1QxwRpY5Pc=",
+}
+
+func TestSpringDecodeVerify(t *testing.T) {
+	for _, in := range springTests {
+		h, err := gophc.DecodeSpring(in)
+		if err != nil {
+			t.Errorf("unexpected error decoding \"%s\": %v", in, err)
+			continue
+		}
+		ok, verifyErr := h.Verify([]byte("password"))
+		if verifyErr != nil || !ok {
+			t.Errorf("expected password to match \"%s\", got %v (error %v)", in, ok, verifyErr)
+		}
+		ok, verifyErr = h.Verify([]byte("wrong"))
+		if verifyErr != nil || ok {
+			t.Errorf("expected wrong password not to match \"%s\", got %v (error %v)", in, ok, verifyErr)
+		}
+		encoded, encodeErr := h.Encode()
+		if encodeErr != nil {
+			t.Errorf("unexpected error encoding \"%s\": %v", in, encodeErr)
+			continue
+		}
+		if encoded != in {
+			t.Errorf("expected encoding \"%s\", got \"%s\"", in, encoded)
+		}
+	}
+}
+
+func TestSpringInvalid(t *testing.T) {
+	if _, err := gophc.DecodeSpring("{noop}password"); !errors.Is(err, gophc.ErrUnknownSpringID) {
+		t.Errorf("expected ErrUnknownSpringID, got %v", err)
+	}
+	if _, err := gophc.DecodeSpring("$2a$10$dXJ3SW6G7P50lGmMkkmwe.20cQQubK3.HZWzG3YB1tlRy.fqvM/BG"); !errors.Is(err, gophc.ErrInvalidSpringHash) {
+		t.Errorf("expected ErrInvalidSpringHash, got %v", err)
+	}
+	if _, err := gophc.DecodeSpring("{argon2}$2a$10$dXJ3SW6G7P50lGmMkkmwe.20cQQubK3.HZWzG3YB1tlRy.fqvM/BG"); !errors.Is(err, gophc.ErrMismatchedFunctionName) {
+		t.Errorf("expected ErrMismatchedFunctionName, got %v", err)
+	}
+}
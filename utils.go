@@ -14,4 +14,36 @@
 
 package gophc
 
+import "crypto/subtle"
+
+// the salt and hash lengths used when a template doesn't specify them, these are the lengths
+// recommended by RFC 9106
+const (
+	DefaultSaltLength = 16
+	DefaultHashLength = 32
+)
+
 const maxInt = int(^uint(0) >> 1)
+
+// constantTimeEqual compares the two slices in constant time (as long as the length is equal).
+func constantTimeEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// wipeBytes overwrites b with zeroes.
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// equalAndWipe compares the computed value with the expected one in constant time, afterwards computed is wiped.
+func equalAndWipe(computed, expected []byte) bool {
+	res := constantTimeEqual(computed, expected)
+	wipeBytes(computed)
+	return res
+}
+
+const maxInt32 = int32(^uint32(0) >> 1)
+
+const maxUint32 = uint64(^uint32(0))